@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+// report: JSON output, keys are formatted like text output
+type report struct {
+	VarsAdded   []string `json:"varsAdded"`
+	VarsRemoved []string `json:"varsRemoved"`
+	Blocks      []block  `json:"blocks"`
+	Trees       []tree   `json:"trees"`
+}
+
+type block struct {
+	Var       string `json:"var"`
+	OldLength int64  `json:"oldLength"`
+	NewLength int64  `json:"newLength"`
+}
+
+type tree struct {
+	Var     string   `json:"var"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+func newReport(d *bom.DiffReport) *report {
+	r := &report{
+		VarsAdded:   append([]string{}, d.VarsAdded...),
+		VarsRemoved: append([]string{}, d.VarsRemoved...),
+		Blocks:      []block{},
+		Trees:       []tree{},
+	}
+	for _, b := range d.Blocks {
+		r.Blocks = append(r.Blocks, block{Var: b.Var, OldLength: b.OldLength, NewLength: b.NewLength})
+	}
+	for _, t := range d.Trees {
+		r.Trees = append(r.Trees, tree{Var: t.Var, Added: formatKeys(t.Added), Removed: formatKeys(t.Removed), Changed: formatKeys(t.Changed)})
+	}
+	return r
+}
+
+func formatKeys(keys [][]byte) []string {
+	list := make([]string, len(keys))
+	for i, k := range keys {
+		list[i] = formatKey(k)
+	}
+	return list
+}
+
+// formatKey: quoted string if key is printable text, hex otherwise
+func formatKey(k []byte) string {
+	if utf8.Valid(k) {
+		s := string(k)
+		printable := true
+		for _, r := range s {
+			if !strconv.IsPrint(r) {
+				printable = false
+				break
+			}
+		}
+		if printable {
+			return strconv.Quote(s)
+		}
+	}
+	return fmt.Sprintf("0x%x", k)
+}
+
+// printDiff: text output, one line for each difference,
+// "+" is only in new file, "-" is only in old file, "~" is changed, keys of trees follow their tree line
+func printDiff(w io.Writer, d *bom.DiffReport) error {
+	lines := []string{}
+	for _, v := range d.VarsAdded {
+		lines = append(lines, "+ var "+v)
+	}
+	for _, v := range d.VarsRemoved {
+		lines = append(lines, "- var "+v)
+	}
+	for _, b := range d.Blocks {
+		lines = append(lines, fmt.Sprintf("~ block %s %d -> %d bytes", b.Var, b.OldLength, b.NewLength))
+	}
+	for _, t := range d.Trees {
+		lines = append(lines, fmt.Sprintf("~ tree %s +%d -%d ~%d", t.Var, len(t.Added), len(t.Removed), len(t.Changed)))
+		for _, k := range t.Added {
+			lines = append(lines, "  + "+formatKey(k))
+		}
+		for _, k := range t.Removed {
+			lines = append(lines, "  - "+formatKey(k))
+		}
+		for _, k := range t.Changed {
+			lines = append(lines, "  ~ "+formatKey(k))
+		}
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+var testDiff = &bom.DiffReport{
+	VarsAdded:   []string{"NEW"},
+	VarsRemoved: []string{"OLD"},
+	Blocks:      []bom.BlockDiff{{Var: "CARHEADER", OldLength: 436, NewLength: 6}},
+	Trees: []bom.TreeDiff{{
+		Var:     "FACETKEYS",
+		Added:   [][]byte{[]byte("AppIcon")},
+		Removed: [][]byte{{0x01, 0x00, 0xff}},
+		Changed: [][]byte{[]byte("test")},
+	}},
+}
+
+func TestPrintDiff(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := printDiff(out, testDiff); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"+ var NEW\n" +
+		"- var OLD\n" +
+		"~ block CARHEADER 436 -> 6 bytes\n" +
+		"~ tree FACETKEYS +1 -1 ~1\n" +
+		"  + \"AppIcon\"\n" +
+		"  - 0x0100ff\n" +
+		"  ~ \"test\"\n"
+	if out.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := printDiff(out, &bom.DiffReport{}); err != nil || out.Len() != 0 {
+		t.Fatalf("equal: %q %v", out.String(), err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	j, err := json.Marshal(newReport(testDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &report{}
+	if err := json.Unmarshal(j, r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Trees) != 1 || r.Trees[0].Removed[0] != "0x0100ff" || r.Blocks[0].NewLength != 6 {
+		t.Fatalf("json: %s", j)
+	}
+}
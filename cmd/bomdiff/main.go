@@ -0,0 +1,75 @@
+// bomdiff: compare vars, named blocks and tree keys of two BOMStore files
+// exit status is 0 if files are equal, 1 if different, 2 on error
+//
+//	bomdiff [-json] old-bom new-bom
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print JSON instead of text")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: bomdiff [-json] old-bom new-bom\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	d, err := diffFiles(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bomdiff: %v\n", err)
+		os.Exit(2)
+	}
+	if *asJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		err = e.Encode(newReport(d))
+	} else {
+		err = printDiff(os.Stdout, d)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bomdiff: %v\n", err)
+		os.Exit(2)
+	}
+	if !d.Equal() {
+		os.Exit(1)
+	}
+}
+
+func diffFiles(a, b string) (*bom.DiffReport, error) {
+	pa, fa, err := open(a)
+	if err != nil {
+		return nil, err
+	}
+	defer fa.Close()
+	pb, fb, err := open(b)
+	if err != nil {
+		return nil, err
+	}
+	defer fb.Close()
+	return bom.Diff(pa, pb)
+}
+
+func open(name string) (bom.BomStore, *os.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	b := bom.New(f)
+	if err := b.Parse(); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return b, f, nil
+}
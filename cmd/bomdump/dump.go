@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+// dump: everything printed by bomdump, also the JSON output
+type dump struct {
+	Header   header     `json:"header"`
+	Blocks   []block    `json:"blocks"`
+	FreeList []extent   `json:"freeList"`
+	Vars     []variable `json:"vars"`
+	Trees    []tree     `json:"trees"`
+	Hex      []block    `json:"hex,omitempty"`
+}
+
+type header struct {
+	Magic          string `json:"magic"`
+	Version        uint32 `json:"version"`
+	NumberOfBlocks uint32 `json:"numberOfBlocks"`
+	IndexOffset    uint32 `json:"indexOffset"`
+	IndexLength    uint32 `json:"indexLength"`
+	VarsOffset     uint32 `json:"varsOffset"`
+	VarsLength     uint32 `json:"varsLength"`
+}
+
+type extent struct {
+	Address uint32 `json:"address"`
+	Length  uint32 `json:"length"`
+}
+
+type variable struct {
+	Index uint32 `json:"index"`
+	Name  string `json:"name"`
+}
+
+type block struct {
+	Index   uint32 `json:"index"`
+	Address uint32 `json:"address"`
+	Length  uint32 `json:"length"`
+	Var     string `json:"var,omitempty"`
+	// hex of block content, only for hex dump
+	Data string `json:"data,omitempty"`
+}
+
+type tree struct {
+	Name      string `json:"name"`
+	Block     uint32 `json:"block"`
+	Version   uint32 `json:"version"`
+	Root      uint32 `json:"root"`
+	BlockSize uint32 `json:"blockSize"`
+	PathCount uint32 `json:"pathCount"`
+	Unknown3  uint8  `json:"unknown3"`
+	Pages     []page `json:"pages"`
+	// error of reading tree entry or walking pages, pages before the error are kept
+	Err string `json:"error,omitempty"`
+	// tree entry can not be read, only Name, Block and Err are set
+	broken bool
+}
+
+type page struct {
+	Index    uint32      `json:"index"`
+	Depth    int         `json:"depth"`
+	Leaf     bool        `json:"leaf"`
+	Count    uint16      `json:"count"`
+	Forward  uint32      `json:"forward"`
+	Backward uint32      `json:"backward"`
+	Entries  []pageEntry `json:"entries,omitempty"`
+}
+
+type pageEntry struct {
+	Value uint32 `json:"value"`
+	Key   uint32 `json:"key"`
+}
+
+// options: what to dump
+type options struct {
+	// list entries of tree pages
+	entries bool
+	// block indexes or var names to hex dump, "all" for every block
+	hex []string
+}
+
+func newDump(b bom.BomStore, o *options) (*dump, error) {
+	h := b.Header()
+	d := &dump{
+		Header: header{
+			Magic:          h.Magic.String(),
+			Version:        h.Version,
+			NumberOfBlocks: h.NumberOfBlocks,
+			IndexOffset:    h.IndexOffset,
+			IndexLength:    h.IndexLength,
+			VarsOffset:     h.VarsOffset,
+			VarsLength:     h.VarsLength,
+		},
+		FreeList: []extent{},
+		Vars:     []variable{},
+		Blocks:   []block{},
+		Trees:    []tree{},
+	}
+	for _, f := range b.FreeList() {
+		d.FreeList = append(d.FreeList, extent{Address: f.Address, Length: f.Length})
+	}
+	for _, v := range b.Vars() {
+		d.Vars = append(d.Vars, variable{Index: v.Index, Name: v.Name})
+	}
+
+	names := map[uint32]string{}
+	for _, v := range d.Vars {
+		names[v.Index] = v.Name
+	}
+	for i, p := range b.Blocks() {
+		if i == 0 || (p.Address == 0 && p.Length == 0) {
+			continue
+		}
+		d.Blocks = append(d.Blocks, block{Index: uint32(i), Address: p.Address, Length: p.Length, Var: names[uint32(i)]})
+	}
+
+	for _, v := range d.Vars {
+		entry, err := b.TreeEntry(v.Name)
+		if errors.Is(err, bom.ErrNotTree) {
+			continue
+		}
+		if err != nil {
+			// one broken tree must not hide the rest of a corrupt file
+			d.Trees = append(d.Trees, tree{Name: v.Name, Block: v.Index, Pages: []page{}, Err: err.Error(), broken: true})
+			continue
+		}
+		d.Trees = append(d.Trees, newTree(b, v.Name, v.Index, entry, o))
+	}
+
+	hex, err := hexBlocks(b, d, o.hex)
+	if err != nil {
+		return nil, err
+	}
+	d.Hex = hex
+	return d, nil
+}
+
+// newTree: walk pages of tree from root, depth first
+func newTree(b bom.BomStore, name string, index uint32, entry *bom.TreeEntry, o *options) tree {
+	t := tree{
+		Name:      name,
+		Block:     index,
+		Version:   entry.Version,
+		Root:      entry.Index,
+		BlockSize: entry.BlockSize,
+		PathCount: entry.PathCount,
+		Unknown3:  entry.Unknown3,
+		Pages:     []page{},
+	}
+	visited := map[uint32]bool{}
+	var walk func(index uint32, depth int) error
+	walk = func(index uint32, depth int) error {
+		if depth > b.Options().MaxTreeDepth {
+			return &bom.LimitError{Limit: "MaxTreeDepth", Value: int64(depth), Max: int64(b.Options().MaxTreeDepth)}
+		}
+		if visited[index] {
+			return fmt.Errorf("page %d: %w", index, bom.ErrTreeCycle)
+		}
+		visited[index] = true
+		p, err := b.ReadTreePage(index)
+		if err != nil {
+			return err
+		}
+		pg := page{Index: index, Depth: depth, Leaf: p.IsLeaf != 0, Count: p.Count, Forward: p.Forward, Backward: p.Backward}
+		if o.entries {
+			for _, pi := range p.List {
+				pg.Entries = append(pg.Entries, pageEntry{Value: pi.ValueIndex, Key: pi.KeyIndex})
+			}
+		}
+		t.Pages = append(t.Pages, pg)
+		if p.IsLeaf != 0 {
+			return nil
+		}
+		for _, pi := range p.List {
+			if err := walk(pi.ValueIndex, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(entry.Index, 0); err != nil {
+		t.Err = err.Error()
+	}
+	return t
+}
+
+// hexBlocks: read blocks selected by index or var name
+func hexBlocks(b bom.BomStore, d *dump, list []string) ([]block, error) {
+	selected := []block{}
+	for _, s := range list {
+		if s == "all" {
+			selected = append(selected, d.Blocks...)
+			continue
+		}
+		found := false
+		for _, bl := range d.Blocks {
+			if bl.Var == s || strconv.FormatUint(uint64(bl.Index), 10) == s {
+				selected = append(selected, bl)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("block not found: %s", s)
+		}
+	}
+	for i := range selected {
+		r, err := b.ReadBlockIndex(selected[i].Index)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		selected[i].Data = hex.EncodeToString(data)
+	}
+	return selected, nil
+}
+
+// print: text output
+func (d *dump) print(w io.Writer) error {
+	h := d.Header
+	p := &printer{w: w}
+	p.printf("header\n")
+	p.printf("  magic          %s\n", h.Magic)
+	p.printf("  version        %d\n", h.Version)
+	p.printf("  numberOfBlocks %d\n", h.NumberOfBlocks)
+	p.printf("  indexOffset    0x%x\n", h.IndexOffset)
+	p.printf("  indexLength    %d\n", h.IndexLength)
+	p.printf("  varsOffset     0x%x\n", h.VarsOffset)
+	p.printf("  varsLength     %d\n", h.VarsLength)
+
+	p.printf("\nblocks %d\n", len(d.Blocks))
+	p.printf("  %6s %10s %8s  %s\n", "index", "address", "length", "var")
+	for _, b := range d.Blocks {
+		p.printf("  %6d 0x%08x %8d  %s\n", b.Index, b.Address, b.Length, b.Var)
+	}
+
+	p.printf("\nfree list %d\n", len(d.FreeList))
+	for _, f := range d.FreeList {
+		p.printf("  0x%08x %8d\n", f.Address, f.Length)
+	}
+
+	p.printf("\nvars %d\n", len(d.Vars))
+	for _, v := range d.Vars {
+		p.printf("  %6d  %s\n", v.Index, v.Name)
+	}
+
+	for _, t := range d.Trees {
+		if t.broken {
+			p.printf("\ntree %s block %d\n  error: %s\n", t.Name, t.Block, t.Err)
+			continue
+		}
+		p.printf("\ntree %s block %d: version %d, root %d, blockSize %d, pathCount %d, unknown3 %d\n", t.Name, t.Block, t.Version, t.Root, t.BlockSize, t.PathCount, t.Unknown3)
+		for _, pg := range t.Pages {
+			kind := "branch"
+			if pg.Leaf {
+				kind = "leaf"
+			}
+			indent := strings.Repeat("  ", pg.Depth+1)
+			p.printf("%spage %d %s count %d forward %d backward %d\n", indent, pg.Index, kind, pg.Count, pg.Forward, pg.Backward)
+			for _, e := range pg.Entries {
+				p.printf("%s  value %d key %d\n", indent, e.Value, e.Key)
+			}
+		}
+		if t.Err != "" {
+			p.printf("  error: %s\n", t.Err)
+		}
+	}
+
+	for _, b := range d.Hex {
+		data, _ := hex.DecodeString(b.Data)
+		p.printf("\nblock %d %s: address 0x%x length %d\n", b.Index, b.Var, b.Address, b.Length)
+		p.printf("%s", hex.Dump(data))
+	}
+	return p.err
+}
+
+// printer: keep first write error
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, a ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, a...)
+}
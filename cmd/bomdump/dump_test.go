@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+func testBom(t *testing.T) bom.BomStore {
+	w := bom.NewWriter()
+	// 2 entries per page, 5 leaves under a branch level
+	w.BlockSize = 12 + 2*8
+	if _, err := w.WriteBlock("HEADER", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	items := []bom.TreeItem{}
+	for i := 0; i < 10; i++ {
+		items = append(items, bom.TreeItem{Key: []byte(fmt.Sprintf("k%d", i)), Value: []byte{byte(i)}})
+	}
+	if _, err := w.WriteTree("TREE", items); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := bom.NewReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDump(t *testing.T) {
+	b := testBom(t)
+	d, err := newDump(b, &options{entries: true, hex: []string{"HEADER"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Header.Magic != "BOMStore" || len(d.Vars) != 2 || len(d.Blocks) != int(d.Header.NumberOfBlocks) {
+		t.Fatalf("dump: %+v", d)
+	}
+	if len(d.Trees) != 1 || d.Trees[0].Name != "TREE" || d.Trees[0].PathCount != 10 || d.Trees[0].Err != "" {
+		t.Fatalf("trees: %+v", d.Trees)
+	}
+	leaves, branches := 0, 0
+	for _, p := range d.Trees[0].Pages {
+		if p.Leaf {
+			leaves++
+			if len(p.Entries) != int(p.Count) {
+				t.Fatalf("page: %+v", p)
+			}
+		} else {
+			branches++
+		}
+	}
+	if leaves != 5 || branches == 0 || d.Trees[0].Pages[0].Leaf || d.Trees[0].Pages[0].Depth != 0 {
+		t.Fatalf("pages: %+v", d.Trees[0].Pages)
+	}
+	if len(d.Hex) != 1 || d.Hex[0].Data != "68656c6c6f" {
+		t.Fatalf("hex: %+v", d.Hex)
+	}
+
+	out := &bytes.Buffer{}
+	if err := d.print(out); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"magic          BOMStore", "tree TREE", "leaf count 2", "|hello|"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("text output has no %q:\n%s", s, out.String())
+		}
+	}
+
+	j, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2 := &dump{}
+	if err := json.Unmarshal(j, d2); err != nil {
+		t.Fatal(err)
+	}
+	if len(d2.Trees[0].Pages) != len(d.Trees[0].Pages) || d2.Hex[0].Data != d.Hex[0].Data {
+		t.Fatalf("json: %s", j)
+	}
+
+	if _, err := newDump(b, &options{hex: []string{"NOTFOUND"}}); err == nil {
+		t.Fatal("want error of unknown block")
+	}
+}
+
+// a broken tree entry is reported on its tree, header, blocks and other trees are still dumped
+func TestDumpBrokenTree(t *testing.T) {
+	w := bom.NewWriter()
+	if _, err := w.WriteTree("BROKEN", []bom.TreeItem{{Key: []byte("k"), Value: []byte("v")}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteTree("TREE", []bom.TreeItem{{Key: []byte("k"), Value: []byte("v")}}); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := bom.NewReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	// move tree entry block of BROKEN out of file
+	d := buf.Bytes()
+	index := b.Vars()[0].Index
+	pointer := binary.BigEndian.Uint32(d[16:]) + 4 + index*8
+	binary.BigEndian.PutUint32(d[pointer:], uint32(len(d)))
+	b = bom.NewReaderAt(bytes.NewReader(d), int64(len(d)))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	dm, err := newDump(b, &options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dm.Header.Magic != "BOMStore" || len(dm.Blocks) != int(dm.Header.NumberOfBlocks) || len(dm.Trees) != 2 {
+		t.Fatalf("dump: %+v", dm)
+	}
+	if tr := dm.Trees[0]; tr.Name != "BROKEN" || tr.Block != index || !strings.Contains(tr.Err, "unexpected EOF") {
+		t.Fatalf("broken tree: %+v", tr)
+	}
+	if tr := dm.Trees[1]; tr.Name != "TREE" || tr.Err != "" || len(tr.Pages) != 1 {
+		t.Fatalf("tree: %+v", tr)
+	}
+
+	out := &bytes.Buffer{}
+	if err := dm.print(out); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"magic          BOMStore", "tree BROKEN block", "  error: ", "tree TREE block"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("text output has no %q:\n%s", s, out.String())
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// bomdump: print header, block table, free list, vars and tree pages of BOMStore files
+//
+//	bomdump [-e] [-json] [-x block,...] bom ...
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+func main() {
+	o := &options{}
+	asJSON := flag.Bool("json", false, "print JSON instead of text")
+	hexList := flag.String("x", "", "hex dump blocks, comma separated block indexes or var names, \"all\" for every block")
+	flag.BoolVar(&o.entries, "e", false, "list entries of tree pages")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: bomdump [-e] [-json] [-x block,...] bom ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *hexList != "" {
+		o.hex = strings.Split(*hexList, ",")
+	}
+
+	for _, name := range flag.Args() {
+		if err := dumpFile(name, o, *asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "bomdump: %v: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func dumpFile(name string, o *options, asJSON bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b := bom.New(f)
+	if err := b.Parse(); err != nil {
+		return err
+	}
+	d, err := newDump(b, o)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(d)
+	}
+	return d.print(os.Stdout)
+}
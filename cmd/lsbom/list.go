@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+// file type bits of st_mode
+const (
+	modeTypeMask = 0170000
+	modeBlock    = 0060000
+	modeChar     = 0020000
+	modeDir      = 0040000
+	modeLink     = 0120000
+	modeFIFO     = 0010000
+	modeSocket   = 0140000
+)
+
+// cpu types of mach-o
+var archs = map[string]uint32{
+	"i386":   7,
+	"x86_64": 0x01000007,
+	"ppc":    18,
+	"ppc64":  0x01000012,
+	"arm":    12,
+	"arm64":  0x0100000c,
+}
+
+type options struct {
+	blockDevices bool
+	charDevices  bool
+	dirs         bool
+	files        bool
+	links        bool
+	modTime      bool
+	pathOnly     bool
+	noModes      bool
+	arch         string
+	params       string
+
+	// name caches of -p G U ?
+	users  map[uint32]string
+	groups map[uint32]string
+}
+
+func (o *options) check() error {
+	if o.arch != "" {
+		if _, ok := archs[o.arch]; !ok {
+			return fmt.Errorf("unknown architecture: %v", o.arch)
+		}
+	}
+	for _, c := range o.params {
+		if !strings.ContainsRune("cfFgGmMsStTuU/?", c) {
+			return fmt.Errorf("unknown parameter: %c", c)
+		}
+	}
+	return nil
+}
+
+// show: filter by type flags, all types are listed without any of -b -c -d -f -l
+func (o *options) show(p *bom.Path) bool {
+	if !o.blockDevices && !o.charDevices && !o.dirs && !o.files && !o.links {
+		return true
+	}
+	switch p.Type {
+	case bom.PathTypeFile:
+		return o.files
+	case bom.PathTypeDir:
+		return o.dirs
+	case bom.PathTypeLink:
+		return o.links
+	case bom.PathTypeDev:
+		if p.Mode&modeTypeMask == modeBlock {
+			return o.blockDevices
+		}
+		return o.charDevices
+	}
+	return false
+}
+
+// list: print paths in lsbom format
+func list(w io.Writer, paths []bom.Path, o *options) error {
+	bw := bufio.NewWriter(w)
+	for i := range paths {
+		p := paths[i]
+		if !o.show(&p) {
+			continue
+		}
+		if o.arch != "" && len(p.Archs) > 0 {
+			// size and checksum of selected architecture only, skip fat files without it
+			found := false
+			for _, a := range p.Archs {
+				if a.CPUType == archs[o.arch] {
+					p.Size, p.Size64 = a.Size, 0
+					p.Checksum = a.Checksum
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		if _, err := bw.WriteString(strings.Join(o.fields(&p), "\t") + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (o *options) fields(p *bom.Path) []string {
+	if o.pathOnly {
+		return []string{p.Path}
+	}
+	if o.params != "" {
+		l := make([]string, 0, len(o.params))
+		for _, c := range o.params {
+			l = append(l, o.param(p, c))
+		}
+		return l
+	}
+
+	l := []string{p.Path}
+	if !o.noModes || (p.Type != bom.PathTypeDir && p.Type != bom.PathTypeLink) {
+		l = append(l, o.param(p, 'm'))
+	}
+	l = append(l, o.param(p, '/'))
+	switch p.Type {
+	case bom.PathTypeFile:
+		if o.modTime {
+			l = append(l, o.param(p, 't'))
+		}
+		l = append(l, o.param(p, 's'), o.param(p, 'c'))
+	case bom.PathTypeLink:
+		l = append(l, o.param(p, 's'), o.param(p, 'c'), p.LinkName)
+	case bom.PathTypeDev:
+		l = append(l, strconv.FormatUint(uint64(p.Dev), 10))
+	}
+	return l
+}
+
+// param: format one parameter of -p
+func (o *options) param(p *bom.Path, c rune) string {
+	switch c {
+	case 'c':
+		return strconv.FormatUint(uint64(p.Checksum), 10)
+	case 'f':
+		return p.Path
+	case 'F':
+		return strconv.Quote(p.Path)
+	case 'g':
+		return strconv.FormatUint(uint64(p.GID), 10)
+	case 'G':
+		return o.groupName(p.GID)
+	case 'm':
+		return strconv.FormatUint(uint64(p.Mode), 8)
+	case 'M':
+		return symbolicMode(p.Mode)
+	case 's':
+		return strconv.FormatUint(p.FileSize(), 10)
+	case 'S':
+		return formatSize(p.FileSize())
+	case 't':
+		return strconv.FormatInt(p.ModTime.Unix(), 10)
+	case 'T':
+		return p.ModTime.Local().Format("Mon Jan _2 15:04:05 2006")
+	case 'u':
+		return strconv.FormatUint(uint64(p.UID), 10)
+	case 'U':
+		return o.userName(p.UID)
+	case '/':
+		return fmt.Sprintf("%d/%d", p.UID, p.GID)
+	case '?':
+		return o.userName(p.UID) + "/" + o.groupName(p.GID)
+	}
+	return ""
+}
+
+func (o *options) userName(id uint32) string {
+	if o.users == nil {
+		o.users = map[uint32]string{}
+	}
+	if n, ok := o.users[id]; ok {
+		return n
+	}
+	n := strconv.FormatUint(uint64(id), 10)
+	if u, err := user.LookupId(n); err == nil {
+		n = u.Username
+	}
+	o.users[id] = n
+	return n
+}
+
+func (o *options) groupName(id uint32) string {
+	if o.groups == nil {
+		o.groups = map[uint32]string{}
+	}
+	if n, ok := o.groups[id]; ok {
+		return n
+	}
+	n := strconv.FormatUint(uint64(id), 10)
+	if g, err := user.LookupGroupId(n); err == nil {
+		n = g.Name
+	}
+	o.groups[id] = n
+	return n
+}
+
+// symbolicMode: mode like ls -l, "drwxr-xr-x"
+func symbolicMode(mode uint16) string {
+	b := []byte("?rwxrwxrwx")
+	switch mode & modeTypeMask {
+	case 0100000:
+		b[0] = '-'
+	case modeDir:
+		b[0] = 'd'
+	case modeLink:
+		b[0] = 'l'
+	case modeBlock:
+		b[0] = 'b'
+	case modeChar:
+		b[0] = 'c'
+	case modeFIFO:
+		b[0] = 'p'
+	case modeSocket:
+		b[0] = 's'
+	}
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) == 0 {
+			b[i+1] = '-'
+		}
+	}
+	// setuid, setgid, sticky
+	special := func(bit uint16, i int, set, unset byte) {
+		if mode&bit == 0 {
+			return
+		}
+		if b[i] == '-' {
+			b[i] = unset
+		} else {
+			b[i] = set
+		}
+	}
+	special(04000, 3, 's', 'S')
+	special(02000, 6, 's', 'S')
+	special(01000, 9, 't', 'T')
+	return string(b)
+}
+
+// formatSize: size with thousands separators, "1,234,567"
+func formatSize(size uint64) string {
+	s := strconv.FormatUint(size, 10)
+	n := len(s) % 3
+	if n == 0 {
+		n = 3
+	}
+	out := s[:n]
+	for i := n; i < len(s); i += 3 {
+		out += "," + s[i:i+3]
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+var testPaths = []bom.Path{
+	{Path: ".", Type: bom.PathTypeDir, Mode: 040755},
+	{Path: "./bin", Type: bom.PathTypeDir, Mode: 040755},
+	{Path: "./bin/ls", Type: bom.PathTypeFile, Mode: 0100755, GID: 80, ModTime: time.Unix(1600000000, 0), Size: 38704, Checksum: 3374285806, Archs: []bom.PathArch{
+		{CPUType: 0x01000007, Size: 19000, Checksum: 1},
+		{CPUType: 0x0100000c, Size: 19704, Checksum: 2},
+	}},
+	{Path: "./bin/sh", Type: bom.PathTypeLink, Mode: 0120755, Size: 4, Checksum: 1234, LinkName: "bash"},
+	{Path: "./dev/disk0", Type: bom.PathTypeDev, Mode: 060640, GID: 5, Dev: 16777216},
+	{Path: "./dev/null", Type: bom.PathTypeDev, Mode: 020666, Dev: 50331650},
+}
+
+func TestList(t *testing.T) {
+	cases := []struct {
+		o    *options
+		want string
+	}{
+		{&options{}, "" +
+			".\t40755\t0/0\n" +
+			"./bin\t40755\t0/0\n" +
+			"./bin/ls\t100755\t0/80\t38704\t3374285806\n" +
+			"./bin/sh\t120755\t0/0\t4\t1234\tbash\n" +
+			"./dev/disk0\t60640\t0/5\t16777216\n" +
+			"./dev/null\t20666\t0/0\t50331650\n"},
+		{&options{files: true, modTime: true}, "./bin/ls\t100755\t0/80\t1600000000\t38704\t3374285806\n"},
+		{&options{dirs: true, links: true, noModes: true}, ".\t0/0\n./bin\t0/0\n./bin/sh\t0/0\t4\t1234\tbash\n"},
+		{&options{blockDevices: true, pathOnly: true}, "./dev/disk0\n"},
+		{&options{charDevices: true, pathOnly: true}, "./dev/null\n"},
+		{&options{files: true, params: "fMsS"}, "./bin/ls\t-rwxr-xr-x\t38704\t38,704\n"},
+		{&options{files: true, links: true, params: "Fug", arch: "arm64"}, "\"./bin/ls\"\t0\t80\n\"./bin/sh\"\t0\t0\n"},
+		{&options{files: true, arch: "arm64"}, "./bin/ls\t100755\t0/80\t19704\t2\n"},
+		{&options{files: true, arch: "i386"}, ""},
+	}
+	for i, c := range cases {
+		buf := &bytes.Buffer{}
+		if err := list(buf, testPaths, c.o); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("case %d:\n%q\nwant:\n%q", i, buf.String(), c.want)
+		}
+	}
+}
+
+func TestSymbolicMode(t *testing.T) {
+	cases := map[uint16]string{
+		040755:  "drwxr-xr-x",
+		0100644: "-rw-r--r--",
+		0104755: "-rwsr-xr-x",
+		041777:  "drwxrwxrwt",
+		0120000: "l---------",
+		020666:  "crw-rw-rw-",
+	}
+	for m, want := range cases {
+		if s := symbolicMode(m); s != want {
+			t.Errorf("%o: %v want %v", m, s, want)
+		}
+	}
+}
+
+func TestOptionsCheck(t *testing.T) {
+	if err := (&options{arch: "mips"}).check(); err == nil {
+		t.Fail()
+	}
+	if err := (&options{params: "fz"}).check(); err == nil {
+		t.Fail()
+	}
+}
@@ -0,0 +1,65 @@
+// lsbom: list contents of installer BOM files, compatible with lsbom of macOS
+//
+//	lsbom [-b] [-c] [-d] [-f] [-l] [-m] [-s] [-x] [--arch archVal] [-p parameters] bom ...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+func main() {
+	o := &options{}
+	flag.BoolVar(&o.blockDevices, "b", false, "list block devices")
+	flag.BoolVar(&o.charDevices, "c", false, "list character devices")
+	flag.BoolVar(&o.dirs, "d", false, "list directories")
+	flag.BoolVar(&o.files, "f", false, "list files")
+	flag.BoolVar(&o.links, "l", false, "list symbolic links")
+	flag.BoolVar(&o.modTime, "m", false, "print modified times for plain files")
+	flag.BoolVar(&o.pathOnly, "s", false, "print only the path of each file")
+	flag.BoolVar(&o.noModes, "x", false, "suppress modes for directories and symlinks")
+	flag.StringVar(&o.arch, "arch", "", "display only the specified architecture of fat files: i386, x86_64, ppc, ppc64, arm, arm64")
+	flag.StringVar(&o.params, "p", "", "print only the given parameters, any of: cfFgGmMsStTuU/?")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: lsbom [-b] [-c] [-d] [-f] [-l] [-m] [-s] [-x] [--arch archVal] [-p parameters] bom ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := o.check(); err != nil {
+		fmt.Fprintf(os.Stderr, "lsbom: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, name := range flag.Args() {
+		if err := listFile(name, o); err != nil {
+			fmt.Fprintf(os.Stderr, "lsbom: %v: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func listFile(name string, o *options) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b := bom.New(f)
+	if err := b.Parse(); err != nil {
+		return err
+	}
+	paths, err := b.Paths()
+	if err != nil {
+		return err
+	}
+	return list(os.Stdout, paths, o)
+}
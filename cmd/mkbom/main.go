@@ -0,0 +1,75 @@
+// mkbom: create installer BOM file from a directory, or from output of lsbom
+//
+//	mkbom [-u uid] [-g gid] source target-bom-file
+//	mkbom [-u uid] [-g gid] -i filelist target-bom-file
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+func main() {
+	listing := flag.String("i", "", "read file list in lsbom format instead of walking a directory")
+	uid := flag.Int("u", -1, "override uid of all files")
+	gid := flag.Int("g", -1, "override gid of all files")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: mkbom [-u uid] [-g gid] source target-bom-file\n       mkbom [-u uid] [-g gid] -i filelist target-bom-file\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if (*listing == "" && len(args) != 2) || (*listing != "" && len(args) != 1) {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if err := mkbom(*listing, args, *uid, *gid); err != nil {
+		fmt.Fprintf(os.Stderr, "mkbom: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func mkbom(listing string, args []string, uid, gid int) error {
+	var paths []bom.Path
+	var err error
+	if listing != "" {
+		f, err := os.Open(listing)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		paths, err = bom.ParseListing(f)
+		if err != nil {
+			return err
+		}
+	} else {
+		paths, err = bom.PathsFromDir(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range paths {
+		if uid >= 0 {
+			paths[i].UID = uint32(uid)
+		}
+		if gid >= 0 {
+			paths[i].GID = uint32(gid)
+		}
+	}
+
+	out, err := os.Create(args[len(args)-1])
+	if err != nil {
+		return err
+	}
+	if err := bom.WriteInstaller(out, paths); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+func readPaths(t *testing.T, name string) []bom.Path {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := bom.New(f)
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := b.Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestMkbom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkbom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	listing := filepath.Join(dir, "list.txt")
+	if err := ioutil.WriteFile(listing, []byte(""+
+		".\t40755\t0/0\n"+
+		"./big\t100644\t501/20\t5000000000\t1\n"+
+		"./sh\t120755\t0/0\t4\t1234\tbash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "list.bom")
+	if err := mkbom(listing, []string{out}, 0, -1); err != nil {
+		t.Fatal(err)
+	}
+	paths := readPaths(t, out)
+	if len(paths) != 3 {
+		t.Fatalf("paths: %+v", paths)
+	}
+	if big := paths[1]; big.Path != "./big" || big.UID != 0 || big.GID != 20 || big.FileSize() != 5000000000 {
+		t.Fatalf("big: %+v", big)
+	}
+	if sh := paths[2]; sh.Type != bom.PathTypeLink || sh.LinkName != "bash" {
+		t.Fatalf("sh: %+v", sh)
+	}
+
+	// directory
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "bin", "tool"), []byte("tool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	out = filepath.Join(dir, "dir.bom")
+	if err := mkbom("", []string{src, out}, 99, 98); err != nil {
+		t.Fatal(err)
+	}
+	paths = readPaths(t, out)
+	got := []string{}
+	for _, p := range paths {
+		if p.UID != 99 || p.GID != 98 {
+			t.Fatalf("%s: %d/%d", p.Path, p.UID, p.GID)
+		}
+		got = append(got, p.Path)
+	}
+	if len(got) != 3 || got[0] != "." || got[1] != "./bin" || got[2] != "./bin/tool" || paths[2].FileSize() != 4 {
+		t.Fatalf("paths: %v", got)
+	}
+
+	if err := mkbom(filepath.Join(dir, "missing.txt"), []string{out}, -1, -1); !os.IsNotExist(err) {
+		t.Fatalf("missing listing: %v", err)
+	}
+	if err := mkbom("", []string{filepath.Join(dir, "missing"), out}, -1, -1); err == nil {
+		t.Fatal("missing directory: no error")
+	}
+}
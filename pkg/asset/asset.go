@@ -78,6 +78,20 @@ type Renditionkeytoken struct {
 	Attributes         []RenditionAttribute
 }
 
+// tag: 'BITMAPKEYS', key is inline name identifier of FACETKEYS
+type BitmapKey struct {
+	// uint32_t version; // = 1
+	Version uint32
+	// uint32_t unknown0; // = 0
+	Unknown0 uint32
+	// uint32_t length; // bytes after this field
+	Length uint32
+	// uint32_t count; // = maximumRenditionKeyTokenCount of KEYFORMAT
+	Count uint32
+	// uint32_t bitmaps[count]; // one for each token of KEYFORMAT
+	Bitmaps []uint32
+}
+
 type RenditionAttribute struct {
 	Name  uint16
 	Value uint16hex
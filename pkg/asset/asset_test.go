@@ -1,20 +1,26 @@
 package asset
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"reflect"
 	"testing"
 
+	"github.com/iineva/bom/pkg/bom"
 	"github.com/iineva/bom/pkg/helper"
 )
 
 func TestAsset(t *testing.T) {
 	fileNames := []string{
-		"../bom/test_data/Assets.Car", "AppIcon",
+		"../bom/test_data/Assets.car", "AppIcon",
 		// "test_data/YouTube.car", "AppIcon",
 		// "test_data/Instagram.car", "AppIcon",
 		// "test_data/Twitter.car", "ProductionAppIcon",
@@ -189,10 +195,220 @@ func TestAssetBom(t *testing.T) {
 		}
 	}
 
-	// TODO:
 	// name: 'BITMAPKEYS'
-	// if err := b.BitmapKeys(); err != nil {
-	// 	t.Fatal(err)
-	// }
+	if c, err := b.BitmapKeys(); err != nil {
+		t.Fatal(err)
+	} else {
+		any := uint32(0xFFFFFFFF)
+		single := BitmapAttrs{
+			kRenditionAttributeType_Scale:      0x02,
+			kRenditionAttributeType_Idiom:      0x01,
+			kRenditionAttributeType_Subtype:    0x01,
+			kRenditionAttributeType_Dimension2: 0x01,
+			kRenditionAttributeType_Identifier: any,
+			kRenditionAttributeType_Element:    any,
+			kRenditionAttributeType_Part:       any,
+		}
+		tc := map[string]BitmapAttrs{
+			"AppIcon": {
+				kRenditionAttributeType_Scale:      0x0E,
+				kRenditionAttributeType_Idiom:      0x02,
+				kRenditionAttributeType_Subtype:    0x00010001,
+				kRenditionAttributeType_Dimension2: 0x03,
+				kRenditionAttributeType_Identifier: any,
+				kRenditionAttributeType_Element:    any,
+				kRenditionAttributeType_Part:       any,
+			},
+			"test":  single,
+			"test2": single,
+			"test3": single,
+		}
+		if !reflect.DeepEqual(tc, c) {
+			t.Fatalf("BITMAPKEYS: %v", c)
+		}
+	}
+}
+
+func TestAssetLimits(t *testing.T) {
+	d, err := ioutil.ReadFile("../bom/test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// images decoded without limits
+	a, err := NewWithReaderAt(bytes.NewReader(d), int64(len(d)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := map[string]bool{}
+	if err := a.Renditions(func(cb *RenditionCallback) (stop bool) {
+		if cb.Type == RenditionTypeImage && cb.Err == nil {
+			decoded[cb.Name] = true
+		}
+		return false
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) == 0 {
+		t.Fatal("no image rendition")
+	}
+
+	cases := map[string]bom.Options{
+		"MaxPixels":            {MaxPixels: 100},
+		"MaxDecompressedBytes": {MaxDecompressedBytes: 100},
+	}
+	for limit, opts := range cases {
+		a, err := NewWithOptions(bytes.NewReader(d), int64(len(d)), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Renditions(func(cb *RenditionCallback) (stop bool) {
+			if !decoded[cb.Name] {
+				return false
+			}
+			le := &bom.LimitError{}
+			if !errors.As(cb.Err, &le) || le.Limit != limit || !errors.Is(cb.Err, bom.ErrLimitExceeded) {
+				t.Errorf("%v: %v: %v", limit, cb.Name, cb.Err)
+			}
+			return false
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImageErrors(t *testing.T) {
+	f, err := os.Open("../bom/test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, err := NewWithReadSeeker(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// renditions of test are compressed with deepmap-2
+	_, err = a.Image("test")
+	re := &RenditionError{}
+	if !errors.Is(err, ErrUnsupportedCompression) || !errors.As(err, &re) || re.Name != "test.png" || re.Format != "ARGB" {
+		t.Fatalf("test: %v", err)
+	}
+
+	if _, err := a.Image("not exists"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("not exists: %v", err)
+	}
+}
+
+func openBench(b *testing.B) *asset {
+	d, err := ioutil.ReadFile("../bom/test_data/Assets.car")
+	if err != nil {
+		b.Fatal(err)
+	}
+	a, err := NewWithReaderAt(bytes.NewReader(d), int64(len(d)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return a
+}
+
+func BenchmarkFacetKeys(b *testing.B) {
+	a := openBench(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := a.FacetKeys(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenditions(b *testing.B) {
+	a := openBench(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := a.Renditions(func(cb *RenditionCallback) bool { return false }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkImage(b *testing.B) {
+	a := openBench(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := a.Image("AppIcon"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// csiHeaders: raw csi headers of all renditions
+func csiHeaders(t testing.TB, a *asset) [][]byte {
+	list := [][]byte{}
+	if err := a.bom.ReadTree("RENDITIONS", func(k io.Reader, d io.Reader) error {
+		h := make([]byte, csiHeaderSize)
+		if _, err := io.ReadFull(d, h); err != nil {
+			return err
+		}
+		list = append(list, h)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+// TestDecodeCSIHeader: hand written decoder matches binary.Read
+func TestDecodeCSIHeader(t *testing.T) {
+	f, err := os.Open("../bom/test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, err := NewWithReadSeeker(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := csiHeaders(t, a)
+	if len(headers) == 0 {
+		t.Fatal("no renditions")
+	}
+	for _, h := range headers {
+		want := &csiheader{}
+		if err := binary.Read(bytes.NewReader(h), binary.LittleEndian, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := decodeCSIHeader(h)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("csi header: %+v %v, want %+v", got, err, want)
+		}
+	}
+	if _, err := decodeCSIHeader(headers[0][:100]); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("short: %v", err)
+	}
+}
 
+// BenchmarkCSIHeader: binary.Read compared with hand written decoder
+func BenchmarkCSIHeader(b *testing.B) {
+	headers := csiHeaders(b, openBench(b))
+	b.Run("binary.Read", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, h := range headers {
+				if err := binary.Read(bytes.NewReader(h), binary.LittleEndian, &csiheader{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("cursor", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, h := range headers {
+				if _, err := decodeCSIHeader(h); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
 }
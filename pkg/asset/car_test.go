@@ -0,0 +1,411 @@
+package asset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"sort"
+	"testing"
+
+	lzfse "github.com/blacktop/lzfse-cgo"
+	"github.com/iineva/bom/pkg/bom"
+	"github.com/iineva/bom/pkg/helper"
+)
+
+// testRendition: rendition of buildCar, TvlLength and RenditionLength of header are set from tlv and data
+type testRendition struct {
+	attrs  RenditionAttrs
+	header csiheader
+	tlv    []byte
+	data   []byte
+}
+
+// testCar: content of catalog built by buildCar
+type testCar struct {
+	tokens      []RenditionAttributeType
+	facets      map[string]RenditionAttrs
+	appearances map[string]uint16
+	renditions  []testRendition
+	// FACETKEYS in reverse byte order, like writers which do not sort keys
+	unsortedFacets bool
+}
+
+// buildCar: minimal Assets.car with KEYFORMAT, FACETKEYS, APPEARANCEKEYS and RENDITIONS
+func buildCar(t *testing.T, c *testCar) *asset {
+	w := bom.NewWriter()
+
+	kf := &bytes.Buffer{}
+	kf.WriteString("tmfk")
+	binary.Write(kf, binary.LittleEndian, []uint32{0, uint32(len(c.tokens))})
+	for _, tk := range c.tokens {
+		binary.Write(kf, binary.LittleEndian, uint32(tk))
+	}
+	if _, err := w.WriteBlock("KEYFORMAT", kf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	facets := []bom.TreeItem{}
+	for name, attrs := range c.facets {
+		v := &bytes.Buffer{}
+		binary.Write(v, binary.LittleEndian, []uint16{0, 0, uint16(len(attrs))})
+		for _, k := range sortedAttrs(attrs) {
+			binary.Write(v, binary.LittleEndian, []uint16{uint16(k), uint16(attrs[k])})
+		}
+		facets = append(facets, bom.TreeItem{Key: []byte(name), Value: v.Bytes()})
+	}
+	if c.unsortedFacets {
+		sort.Slice(facets, func(i, j int) bool { return bytes.Compare(facets[i].Key, facets[j].Key) > 0 })
+		if _, err := w.WriteTree("FACETKEYS", facets); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		writeTree(t, w, "FACETKEYS", facets)
+	}
+
+	if c.appearances != nil {
+		items := []bom.TreeItem{}
+		for name, v := range c.appearances {
+			d := make([]byte, 2)
+			binary.BigEndian.PutUint16(d, v)
+			items = append(items, bom.TreeItem{Key: []byte(name), Value: d})
+		}
+		writeTree(t, w, "APPEARANCEKEYS", items)
+	}
+
+	renditions := []bom.TreeItem{}
+	for _, r := range c.renditions {
+		k := &bytes.Buffer{}
+		for _, tk := range c.tokens {
+			binary.Write(k, binary.LittleEndian, uint16(r.attrs[tk]))
+		}
+		h := r.header
+		h.Csibitmaplist.TvlLength = uint32(len(r.tlv))
+		h.Csibitmaplist.RenditionLength = uint32(len(r.data))
+		v := &bytes.Buffer{}
+		binary.Write(v, binary.LittleEndian, &h)
+		v.Write(r.tlv)
+		v.Write(r.data)
+		renditions = append(renditions, bom.TreeItem{Key: k.Bytes(), Value: v.Bytes()})
+	}
+	writeTree(t, w, "RENDITIONS", renditions)
+
+	out := &bytes.Buffer{}
+	if _, err := w.WriteTo(out); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewWithReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func writeTree(t *testing.T, w *bom.Writer, name string, items []bom.TreeItem) {
+	sort.Slice(items, func(i, j int) bool { return bytes.Compare(items[i].Key, items[j].Key) < 0 })
+	if _, err := w.WriteTree(name, items); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sortedAttrs(attrs RenditionAttrs) []RenditionAttributeType {
+	list := []RenditionAttributeType{}
+	for k := range attrs {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// testHeader: csi header of rendition with pixel format, like "DATA", and layout
+func testHeader(name, format string, layout RenditionLayoutType) csiheader {
+	h := csiheader{
+		Tag:         helper.NewString4("ISTC"),
+		Version:     1,
+		PixelFormat: helper.NewString4(format),
+	}
+	helper.Reverse(h.PixelFormat[:len(format)])
+	h.Csimetadata.Layout = layout
+	h.Csimetadata.Name = helper.NewString128(name)
+	return h
+}
+
+// tlv: encode TLV entries in type order
+func tlv(entries map[RenditionTLVType][]byte) []byte {
+	b := &bytes.Buffer{}
+	keys := []RenditionTLVType{}
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, k := range keys {
+		binary.Write(b, binary.LittleEndian, []uint32{uint32(k), uint32(len(entries[k]))})
+		b.Write(entries[k])
+	}
+	return b.Bytes()
+}
+
+// testTokens: KEYFORMAT of testCatalog, Identifier is not the first token, like catalogs of actool
+var testTokens = []RenditionAttributeType{
+	kRenditionAttributeType_Scale,
+	kRenditionAttributeType_ThemeAppearance,
+	kRenditionAttributeType_Idiom,
+	kRenditionAttributeType_DisplayGamut,
+	kRenditionAttributeType_Identifier,
+}
+
+// testFacets: names of testCatalog, one identifier for each
+var testFacets = map[string]uint16hex{
+	// colors
+	"Brand": 0x1234,
+	// data
+	"config":      0x10,
+	"model":       0x20,
+	"broken.json": 0x30,
+	// encoded images
+	"raw":        0x40,
+	"lzfse":      0x41,
+	"heif":       0x42,
+	"broken.jpg": 0x43,
+	// vectors
+	"icon":       0x50,
+	"svg":        0x51,
+	"logo":       0x52,
+	"broken.pdf": 0x53,
+}
+
+// testCatalogRendition: row of testCatalog, file is the name in csi header, layout 0 is kRenditionLayoutType_TextureImage
+type testCatalogRendition struct {
+	facet                           string
+	scale, appearance, idiom, gamut uint16hex
+	file, format                    string
+	layout                          RenditionLayoutType
+	tlv                             []byte
+	data                            []byte
+	// changes csi header, like size and flags
+	header func(h *csiheader)
+}
+
+// testCatalog: one catalog for tests of every accessor, with renditions of table and extra
+func testCatalog(t *testing.T, extra ...testCatalogRendition) *asset {
+	jpg := testJPEG(t, 8, 4)
+	table := []testCatalogRendition{
+		{facet: "Brand", file: "Brand.colorset", layout: kRenditionLayoutType_Color, data: colorData(ColorSpaceSRGB, 1, 0.5, 0, 1)},
+		{facet: "Brand", appearance: 1, file: "Brand.colorset", layout: kRenditionLayoutType_Color, data: colorData(ColorSpaceExtendedRangeSRGB, 1.2, -0.1, 0, 0.5)},
+		{facet: "Brand", idiom: 1, gamut: 1, file: "Brand.colorset", layout: kRenditionLayoutType_Color, data: colorData(ColorSpaceDisplayP3, 0, 0, 1, 1)},
+		{facet: "Brand", appearance: 1, idiom: 2, file: "Brand.colorset", layout: kRenditionLayoutType_Color, data: colorData(ColorSpaceGrayGamma2_2, 0.5, 1)},
+
+		{facet: "config", file: "config.json", format: "DATA", layout: kRenditionLayoutType_Data, tlv: utiTLV("public.json"), data: rawData(`{"any":1}`)},
+		{facet: "config", appearance: 1, file: "config.json", format: "DATA", layout: kRenditionLayoutType_Data, tlv: utiTLV("public.json"), data: rawData(`{"dark":1}`)},
+		{facet: "config", idiom: 2, file: "config.json", format: "DATA", layout: kRenditionLayoutType_Data, tlv: utiTLV("public.json"), data: rawData(`{"pad":1}`)},
+		{facet: "model", appearance: 1, idiom: 1, file: "config.json", format: "DATA", layout: kRenditionLayoutType_Data, tlv: utiTLV("public.json"), data: rawData(`{"phone dark":1}`)},
+		{facet: "broken.json", file: "config.json", format: "DATA", layout: kRenditionLayoutType_Data, data: rawData(`{}`)[:10]},
+
+		{facet: "raw", file: "photo.jpg", format: "JPEG", data: rawData(string(jpg))},
+		{facet: "lzfse", file: "photo.jpg", format: "JPEG", data: pixelRendition(kRenditionCompressionType_jpeg_lzfse, lzfse.EncodeBuffer(jpg))},
+		{facet: "heif", file: "photo.heic", format: "HEIF", data: rawData(string(testHEIF(64, 32)))},
+		{facet: "broken.jpg", file: "photo.jpg", format: "JPEG", data: rawData("not a jpeg")},
+
+		{facet: "icon", file: "icon.pdf", format: "PDF ", data: rawData(testPDF), header: func(h *csiheader) {
+			h.Width, h.Height, h.ScaleFactor = 24, 20, 200
+		}},
+		{facet: "svg", file: "icon.svg", layout: kRenditionLayoutType_Vector, data: rawData(testSVG)},
+		{facet: "logo", scale: 3, file: "logo.pdf", data: rawData(testPDF), header: func(h *csiheader) {
+			h.RenditionFlags = 1 << 11 // preservedVectorRepresentation
+		}},
+		{facet: "broken.pdf", file: "broken.pdf", format: "PDF ", layout: kRenditionLayoutType_Vector, data: []byte("XXXX\x00\x00\x00\x00\x00\x00\x00\x00")},
+	}
+
+	c := &testCar{tokens: testTokens, facets: map[string]RenditionAttrs{}, appearances: map[string]uint16{"UIAppearanceAny": 0, "UIAppearanceDark": 1}}
+	for name, id := range testFacets {
+		c.facets[name] = RenditionAttrs{kRenditionAttributeType_Identifier: id}
+	}
+	for _, r := range append(table, extra...) {
+		layout := r.layout
+		if layout == 0 {
+			layout = kRenditionLayoutType_TextureImage
+		}
+		h := testHeader(r.file, r.format, layout)
+		if r.header != nil {
+			r.header(&h)
+		}
+		c.renditions = append(c.renditions, testRendition{
+			attrs: RenditionAttrs{
+				kRenditionAttributeType_Scale:           r.scale,
+				kRenditionAttributeType_ThemeAppearance: r.appearance,
+				kRenditionAttributeType_Idiom:           r.idiom,
+				kRenditionAttributeType_DisplayGamut:    r.gamut,
+				kRenditionAttributeType_Identifier:      testFacets[r.facet],
+			},
+			header: h,
+			tlv:    r.tlv,
+			data:   r.data,
+		})
+	}
+	return buildCar(t, c)
+}
+
+func colorData(space ColorSpaceID, components ...float64) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("RLOC")
+	binary.Write(b, binary.LittleEndian, []uint32{1, uint32(space), uint32(len(components))})
+	for _, c := range components {
+		binary.Write(b, binary.LittleEndian, math.Float64bits(c))
+	}
+	return b.Bytes()
+}
+
+func utiTLV(uti string) []byte {
+	v := &bytes.Buffer{}
+	binary.Write(v, binary.LittleEndian, []uint32{uint32(len(uti) + 1), 0})
+	v.WriteString(uti + "\x00")
+	return tlv(map[RenditionTLVType][]byte{
+		kRenditionTLVType_Slices: {1, 2, 3, 4},
+		kRenditionTLVType_UTI:    v.Bytes(),
+	})
+}
+
+func rawData(d string) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("DWAR")
+	binary.Write(b, binary.LittleEndian, []uint32{1, uint32(len(d))})
+	b.WriteString(d)
+	return b.Bytes()
+}
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{0xff, 0, 0, 0xff})
+		}
+	}
+	b := &bytes.Buffer{}
+	if err := jpeg.Encode(b, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// pixelRendition: 'CELM' rendition of data with compression
+func pixelRendition(compression RenditionCompressionType, d []byte) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("MLEC")
+	binary.Write(b, binary.LittleEndian, []uint32{0, uint32(compression), uint32(len(d))})
+	b.Write(d)
+	return b.Bytes()
+}
+
+// box: ISO BMFF box, full boxes have version and flags in body
+func box(typ string, body ...[]byte) []byte {
+	d := bytes.Join(body, nil)
+	b := make([]byte, 8, 8+len(d))
+	binary.BigEndian.PutUint32(b, uint32(8+len(d)))
+	copy(b[4:], typ)
+	return append(b, d...)
+}
+
+func ispe(w, h uint32) []byte {
+	d := make([]byte, 12)
+	binary.BigEndian.PutUint32(d[4:], w)
+	binary.BigEndian.PutUint32(d[8:], h)
+	return box("ispe", d)
+}
+
+// testHEIF: boxes of HEIF file with a grid image, tiles are smaller than the image
+func testHEIF(w, h uint32) []byte {
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("heic\x00\x00\x00\x00mif1heic")),
+		box("meta", []byte{0, 0, 0, 0},
+			box("hdlr", make([]byte, 24)),
+			box("iprp",
+				box("ipco", ispe(w/2, h/2), ispe(w, h), ispe(w/2, h/2)),
+				box("ipma", make([]byte, 8)),
+			),
+		),
+		box("mdat", []byte{1, 2, 3}),
+	}, nil)
+}
+
+const (
+	testPDF = "%PDF-1.3\n1 0 obj << /Type /Page /MediaBox [0 0 30.5 40] >> endobj\n%%EOF\n"
+	testSVG = `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="100%" viewBox="0 0 16 12"><path d="M0 0h16v12z"/></svg>`
+)
+
+// only renditions of the asked name are decoded, a rendition which can not be decoded does not stop others
+func TestRenditionsOf(t *testing.T) {
+	a := testCatalog(t, testCatalogRendition{facet: "unknown", file: "x", format: "XXXX", data: []byte{1}})
+	if err := a.Renditions(func(cb *RenditionCallback) (stop bool) { return false }); !errors.Is(err, ErrUnsupportedPixelFormat) {
+		t.Fatalf("walk every rendition: %v", err)
+	}
+	if _, err := a.DataAsset("config"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.EncodedImage("raw"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Vector("icon"); err != nil {
+		t.Fatal(err)
+	}
+
+	// types are filtered before decoding, a broken image is not seen by DataAsset
+	n := 0
+	if err := a.renditionsOf(RenditionAttrs{kRenditionAttributeType_Identifier: testFacets["config"]}, RenditionTypeData, func(cb *RenditionCallback) (stop bool) {
+		n++
+		return false
+	}); err != nil || n != 3 {
+		t.Fatalf("config: %d %v", n, err)
+	}
+	n = 0
+	if err := a.renditionsOf(RenditionAttrs{kRenditionAttributeType_Identifier: testFacets["config"]}, RenditionTypeColor, func(cb *RenditionCallback) (stop bool) {
+		n++
+		return false
+	}); err != nil || n != 0 {
+		t.Fatalf("config colors: %d %v", n, err)
+	}
+
+	if attrs, err := a.facet("Brand"); err != nil || len(attrs) != 1 || attrs[kRenditionAttributeType_Identifier] != 0x1234 {
+		t.Fatalf("facet: %v %v", attrs, err)
+	}
+	if _, err := a.facet("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing facet: %v", err)
+	}
+}
+
+// facets not found by Lookup are found by reading FACETKEYS
+func TestUnsortedFacets(t *testing.T) {
+	a := buildCar(t, &testCar{
+		tokens:         testTokens,
+		facets:         map[string]RenditionAttrs{"a": {kRenditionAttributeType_Identifier: 1}, "b": {kRenditionAttributeType_Identifier: 2}, "c": {kRenditionAttributeType_Identifier: 3}},
+		unsortedFacets: true,
+	})
+	for name, id := range map[string]uint16hex{"a": 1, "b": 2, "c": 3} {
+		if attrs, err := a.facet(name); err != nil || attrs[kRenditionAttributeType_Identifier] != id {
+			t.Fatalf("facet %s: %v %v", name, attrs, err)
+		}
+	}
+	if _, err := a.facet("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing facet: %v", err)
+	}
+}
+
+// plainParser: parser with only the methods of bom.BomParser
+type plainParser struct {
+	bom.BomParser
+}
+
+func TestPlainParser(t *testing.T) {
+	a := New(plainParser{testCatalog(t).bom})
+	if a.opts != bom.DefaultOptions {
+		t.Fatalf("options: %+v", a.opts)
+	}
+	if r, err := a.DataAsset("config"); err != nil || r.Appearance != "UIAppearanceAny" {
+		t.Fatalf("DataAsset: %+v %v", r, err)
+	}
+	if _, err := a.facet("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing facet: %v", err)
+	}
+}
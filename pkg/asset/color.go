@@ -0,0 +1,202 @@
+package asset
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+
+	"github.com/iineva/bom/pkg/helper"
+)
+
+type ColorSpaceID uint8
+
+const (
+	ColorSpaceSRGB               = ColorSpaceID(0)
+	ColorSpaceGrayGamma2_2       = ColorSpaceID(1)
+	ColorSpaceDisplayP3          = ColorSpaceID(2)
+	ColorSpaceExtendedRangeSRGB  = ColorSpaceID(3)
+	ColorSpaceExtendedLinearSRGB = ColorSpaceID(4)
+	ColorSpaceExtendedGray       = ColorSpaceID(5)
+)
+
+func (c ColorSpaceID) String() string {
+	switch c {
+	case ColorSpaceSRGB:
+		return "srgb"
+	case ColorSpaceGrayGamma2_2:
+		return "gray-gamma-22"
+	case ColorSpaceDisplayP3:
+		return "display-p3"
+	case ColorSpaceExtendedRangeSRGB:
+		return "extended-srgb"
+	case ColorSpaceExtendedLinearSRGB:
+		return "extended-linear-srgb"
+	case ColorSpaceExtendedGray:
+		return "extended-gray"
+	default:
+		return fmt.Sprintf("Unknown %d", uint8(c))
+	}
+}
+
+// gray color spaces have white and alpha components, others have red, green, blue and alpha
+func (c ColorSpaceID) gray() bool {
+	return c == ColorSpaceGrayGamma2_2 || c == ColorSpaceExtendedGray
+}
+
+// ColorRendition: rendition of kRenditionLayoutType_Color
+type ColorRendition struct {
+	// uint32_t tag; // 'COLR'
+	Tag helper.String4
+	// uint32_t version;
+	Version uint32
+	// struct { uint32_t colorSpace:8; uint32_t unknown0:3; uint32_t reserved:21; } colorSpace;
+	ColorSpace ColorSpaceID
+	// uint32_t numberOfComponents;
+	NumberOfComponents uint32
+	// double components[];
+	Components []float64
+}
+
+// Color: components as color.Color, without color management,
+// values out of [0, 1] of extended color spaces are clamped
+func (c *ColorRendition) Color() color.Color {
+	v := func(i int) uint16 {
+		if i >= len(c.Components) {
+			return 0xffff
+		}
+		f := c.Components[i]
+		if math.IsNaN(f) || f < 0 {
+			return 0
+		}
+		if f > 1 {
+			return 0xffff
+		}
+		return uint16(math.Round(f * 0xffff))
+	}
+	if c.ColorSpace.gray() {
+		w := v(0)
+		return color.NRGBA64{R: w, G: w, B: w, A: v(1)}
+	}
+	return color.NRGBA64{R: v(0), G: v(1), B: v(2), A: v(3)}
+}
+
+func decodeColor(r io.Reader) (*ColorRendition, error) {
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cur := helper.NewCursor(d, binary.LittleEndian)
+	c := &ColorRendition{}
+	cur.Fill(c.Tag[:])
+	c.Version = cur.Uint32()
+	c.ColorSpace = ColorSpaceID(cur.Uint32() & 0xff)
+	c.NumberOfComponents = cur.Uint32()
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	// every component is 8 bytes
+	if uint64(c.NumberOfComponents)*8 > uint64(cur.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	c.Components = make([]float64, c.NumberOfComponents)
+	for i := range c.Components {
+		c.Components[i] = math.Float64frombits(cur.Uint64())
+	}
+	return c, nil
+}
+
+// NamedColor: one variant of named color
+type NamedColor struct {
+	Name string
+	// name in APPEARANCEKEYS, like "UIAppearanceDark", empty for any appearance
+	Appearance string
+	// "universal", "phone", "pad", "tv", "car", "watch", "marketing"
+	Idiom string
+	// "sRGB" or "display-P3"
+	Gamut string
+	Attrs RenditionAttrs
+	*ColorRendition
+}
+
+func idiomName(v uint16hex) string {
+	if v == 0 {
+		return "universal"
+	}
+	if kCoreThemeIdiom(v) < kCoreThemeIdiomMax {
+		return kCoreThemeIdiomNames[v]
+	}
+	return fmt.Sprintf("Unknown %d", uint16(v))
+}
+
+func gamutName(v uint16hex) string {
+	switch v {
+	case 0:
+		return "sRGB"
+	case 1:
+		return "display-P3"
+	default:
+		return fmt.Sprintf("Unknown %d", uint16(v))
+	}
+}
+
+// Colors: every variant of named colors, sorted by name, appearance, idiom and gamut
+// colors without facet are named by hex identifier, like "1AC1"
+// renditions which can not be decoded are skipped, the first error is returned with the other colors
+func (a *asset) Colors() ([]*NamedColor, error) {
+	names, err := a.facetNames()
+	if err != nil {
+		return nil, err
+	}
+	appearances, err := a.appearanceNames()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*NamedColor{}
+	var colorErr error
+	if err := a.renditionsOf(nil, RenditionTypeColor, func(cb *RenditionCallback) (stop bool) {
+		if cb.Err != nil {
+			if colorErr == nil {
+				colorErr = cb.Err
+			}
+			return false
+		}
+		id := cb.Attrs[kRenditionAttributeType_Identifier]
+		name, ok := names[id]
+		if !ok {
+			name = id.String()
+		}
+		c := &NamedColor{
+			Name:           name,
+			Idiom:          idiomName(cb.Attrs[kRenditionAttributeType_Idiom]),
+			Gamut:          gamutName(cb.Attrs[kRenditionAttributeType_DisplayGamut]),
+			Attrs:          cb.Attrs,
+			ColorRendition: cb.Color,
+		}
+		if v, ok := cb.Attrs[kRenditionAttributeType_ThemeAppearance]; ok {
+			c.Appearance = appearances[v]
+		}
+		list = append(list, c)
+		return false
+	}); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Appearance != b.Appearance {
+			return a.Appearance < b.Appearance
+		}
+		if a.Idiom != b.Idiom {
+			return a.Idiom < b.Idiom
+		}
+		return a.Gamut < b.Gamut
+	})
+	return list, colorErr
+}
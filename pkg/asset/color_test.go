@@ -0,0 +1,68 @@
+package asset
+
+import (
+	"errors"
+	"image/color"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestColors(t *testing.T) {
+	a := testCatalog(t)
+	colors, err := a.Colors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		appearance, idiom, gamut string
+		space                    ColorSpaceID
+		color                    color.NRGBA64
+	}{
+		{"UIAppearanceAny", "phone", "display-P3", ColorSpaceDisplayP3, color.NRGBA64{0, 0, 0xffff, 0xffff}},
+		{"UIAppearanceAny", "universal", "sRGB", ColorSpaceSRGB, color.NRGBA64{0xffff, 0x8000, 0, 0xffff}},
+		{"UIAppearanceDark", "pad", "sRGB", ColorSpaceGrayGamma2_2, color.NRGBA64{0x8000, 0x8000, 0x8000, 0xffff}},
+		{"UIAppearanceDark", "universal", "sRGB", ColorSpaceExtendedRangeSRGB, color.NRGBA64{0xffff, 0, 0, 0x8000}},
+	}
+	if len(colors) != len(want) {
+		t.Fatalf("colors: %d", len(colors))
+	}
+	for i, w := range want {
+		c := colors[i]
+		if c.Name != "Brand" || c.Appearance != w.appearance || c.Idiom != w.idiom || c.Gamut != w.gamut || c.ColorSpace != w.space {
+			t.Fatalf("color %d: %+v %+v", i, c, c.ColorRendition)
+		}
+		if c.Color() != w.color {
+			t.Fatalf("color %d: %v, want %v", i, c.Color(), w.color)
+		}
+	}
+	if colors[3].Components[0] != 1.2 || colors[3].NumberOfComponents != 4 {
+		t.Fatalf("raw components: %v", colors[3].Components)
+	}
+
+	// truncated components are skipped, other colors are returned with the error
+	a = testCatalog(t, testCatalogRendition{facet: "Brand", gamut: 1, file: "Brand.colorset", layout: kRenditionLayoutType_Color, data: colorData(ColorSpaceSRGB, 1, 0.5, 0, 1)[:30]})
+	colors, err = a.Colors()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated: %v", err)
+	}
+	if len(colors) != len(want) {
+		t.Fatalf("truncated: %d colors", len(colors))
+	}
+}
+
+func TestColorsAssetsCar(t *testing.T) {
+	f, err := os.Open("../bom/test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, err := NewWithReadSeeker(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors, err := a.Colors()
+	if err != nil || len(colors) != 0 {
+		t.Fatalf("colors: %v %v", colors, err)
+	}
+}
@@ -0,0 +1,174 @@
+package asset
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iineva/bom/pkg/helper"
+)
+
+var ErrNoMatch = errors.New("no rendition matches traits")
+
+// RawDataRendition: header of DATA rendition, raw data follows
+type RawDataRendition struct {
+	// uint32_t tag; // 'RAWD'
+	Tag helper.String4
+	// uint32_t version;
+	Version uint32
+	// uint32_t rawDataLength;
+	RawDataLength uint32
+	// uint8_t rawData[];
+}
+
+// decodeRawData: data of DATA rendition, n is the length of rendition data
+// the returned reader shares the block reader r, nothing is read into memory
+func decodeRawData(r io.Reader, n uint32) (io.Reader, error) {
+	h := make([]byte, 12)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	c := helper.NewCursor(h, binary.LittleEndian)
+	p := &RawDataRendition{}
+	c.Fill(p.Tag[:])
+	p.Version = c.Uint32()
+	p.RawDataLength = c.Uint32()
+	if n < 12 || p.RawDataLength > n-12 {
+		return nil, fmt.Errorf("raw data length %d, rendition length %d: %w", p.RawDataLength, n, io.ErrUnexpectedEOF)
+	}
+	return io.LimitReader(r, int64(p.RawDataLength)), nil
+}
+
+// decodeTLV: values of TLV section by type, entries are type, length and value
+// a truncated last entry is ignored
+func decodeTLV(d []byte) map[RenditionTLVType][]byte {
+	list := map[RenditionTLVType][]byte{}
+	c := helper.NewCursor(d, binary.LittleEndian)
+	for c.Len() >= 8 {
+		t := RenditionTLVType(c.Uint32())
+		v := c.Bytes(int(c.Uint32()))
+		if c.Err() != nil {
+			break
+		}
+		list[t] = v
+	}
+	return list
+}
+
+// decodeUTI: value of kRenditionTLVType_UTI is string length, 4 unknown bytes and string
+func decodeUTI(v []byte) string {
+	c := helper.NewCursor(v, binary.LittleEndian)
+	n := c.Uint32()
+	c.Skip(4)
+	if c.Err() != nil {
+		return ""
+	}
+	if int64(n) > int64(c.Len()) {
+		n = uint32(c.Len())
+	}
+	return strings.TrimRight(string(c.Bytes(int(n))), "\x00")
+}
+
+// Traits: appearance and idiom of device, like trait collection of UIKit
+type Traits struct {
+	// name in APPEARANCEKEYS, like "UIAppearanceDark", empty is any appearance
+	Appearance string
+	// "phone", "pad", "tv", "car", "watch", "marketing", empty is "universal"
+	Idiom string
+}
+
+// DataRendition: data asset, like NSDataAsset
+type DataRendition struct {
+	Name string
+	// file name in catalog
+	FileName   string
+	UTI        string
+	Appearance string
+	Idiom      string
+	Attrs      RenditionAttrs
+	// raw data, it can be read while the file is open
+	io.Reader
+}
+
+// DataAsset: data asset of name for any appearance and universal idiom, like NSDataAsset(name:)
+func (a *asset) DataAsset(name string) (*DataRendition, error) {
+	return a.DataAssetFor(name, Traits{})
+}
+
+// DataAssetFor: data asset of name which matches traits best,
+// the rendition of idiom is used before universal one, and the rendition of appearance before any appearance
+func (a *asset) DataAssetFor(name string, traits Traits) (*DataRendition, error) {
+	attrs, err := a.facet(name)
+	if err != nil {
+		return nil, err
+	}
+	appearances, err := a.appearanceNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var best *DataRendition
+	bestScore := 0
+	var dataErr error
+	if err := a.renditionsOf(attrs, RenditionTypeData, func(cb *RenditionCallback) (stop bool) {
+		r := &DataRendition{
+			Name:       name,
+			FileName:   cb.Name,
+			UTI:        cb.UTI,
+			Appearance: appearances[cb.Attrs[kRenditionAttributeType_ThemeAppearance]],
+			Idiom:      idiomName(cb.Attrs[kRenditionAttributeType_Idiom]),
+			Attrs:      cb.Attrs,
+			Reader:     cb.Data,
+		}
+		score := traits.score(r)
+		if score <= bestScore {
+			return false
+		}
+		if cb.Err != nil {
+			if dataErr == nil {
+				dataErr = cb.Err
+			}
+			return false
+		}
+		best, bestScore = r, score
+		return false
+	}); err != nil {
+		return nil, err
+	}
+	if best != nil {
+		return best, nil
+	}
+	if dataErr != nil {
+		return nil, dataErr
+	}
+	return nil, &RenditionError{Name: name, Err: ErrNoMatch}
+}
+
+// score: 0 if r can not be used for traits, higher is better, idiom is more important than appearance
+func (t Traits) score(r *DataRendition) int {
+	idiom := matchTrait(t.Idiom, r.Idiom, "universal")
+	appearance := matchTrait(t.Appearance, r.Appearance, "UIAppearanceAny")
+	if idiom == 0 || appearance == 0 {
+		return 0
+	}
+	return idiom*3 + appearance
+}
+
+// matchTrait: 2 if value is want, 1 if value is the default any, 0 otherwise, empty is any
+func matchTrait(want, value, any string) int {
+	if want == "" {
+		want = any
+	}
+	if value == "" {
+		value = any
+	}
+	switch value {
+	case want:
+		return 2
+	case any:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package asset
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDataAsset(t *testing.T) {
+	a := testCatalog(t)
+	cases := []struct {
+		name   string
+		traits Traits
+		want   string
+	}{
+		{"config", Traits{}, `{"any":1}`},
+		{"config", Traits{Appearance: "UIAppearanceDark"}, `{"dark":1}`},
+		{"config", Traits{Idiom: "phone"}, `{"any":1}`},
+		{"config", Traits{Idiom: "pad", Appearance: "UIAppearanceDark"}, `{"pad":1}`},
+		{"model", Traits{Idiom: "phone", Appearance: "UIAppearanceDark"}, `{"phone dark":1}`},
+	}
+	for _, c := range cases {
+		r, err := a.DataAssetFor(c.name, c.traits)
+		if err != nil {
+			t.Fatalf("%s %+v: %v", c.name, c.traits, err)
+		}
+		d, err := ioutil.ReadAll(r)
+		if err != nil || string(d) != c.want || r.UTI != "public.json" || r.FileName != "config.json" {
+			t.Fatalf("%s %+v: %q %+v %v", c.name, c.traits, d, r, err)
+		}
+	}
+
+	if r, err := a.DataAsset("config"); err != nil || r.Appearance != "UIAppearanceAny" || r.Idiom != "universal" {
+		t.Fatalf("DataAsset: %+v %v", r, err)
+	}
+	errs := []struct {
+		name string
+		want error
+	}{
+		{"model", ErrNoMatch},
+		{"missing", ErrNotFound},
+		{"broken.json", io.ErrUnexpectedEOF},
+		{"Brand", ErrNoMatch},
+	}
+	for _, e := range errs {
+		if _, err := a.DataAsset(e.name); !errors.Is(err, e.want) {
+			t.Fatalf("%s: %v, want %v", e.name, err, e.want)
+		}
+	}
+}
+
+func TestDecodeTLV(t *testing.T) {
+	d := utiTLV("com.apple.coreml.model")
+	list := decodeTLV(append(d, 0xee, 0x03, 0, 0, 9))
+	if len(list) != 2 || decodeUTI(list[kRenditionTLVType_UTI]) != "com.apple.coreml.model" {
+		t.Fatalf("tlv: %v", list)
+	}
+	if decodeUTI([]byte{1, 0}) != "" {
+		t.Fatal("short UTI")
+	}
+}
+
+func TestDataAssetAssetsCar(t *testing.T) {
+	f, err := os.Open("../bom/test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, err := NewWithReadSeeker(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.DataAsset("AppIcon"); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("image is not data: %v", err)
+	}
+}
@@ -2,11 +2,11 @@ package asset
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"image"
 	"io"
 	"io/ioutil"
-	"log"
 	"strings"
 
 	"github.com/iineva/bom/pkg/bom"
@@ -39,11 +39,17 @@ type AssetParser interface {
 }
 
 type asset struct {
-	bom bom.BomParser
+	bom  bom.BomParser
+	opts bom.Options
 }
 
+// New: asset of parsed bom, limits are from b.Options() when b is a bom.BomStore, bom.DefaultOptions otherwise
 func New(b bom.BomParser) *asset {
-	return &asset{bom: b}
+	opts := bom.DefaultOptions
+	if s, ok := b.(bom.BomStore); ok {
+		opts = s.Options()
+	}
+	return &asset{bom: b, opts: opts}
 }
 
 func NewWithReadSeeker(r io.ReadSeeker) (*asset, error) {
@@ -51,7 +57,21 @@ func NewWithReadSeeker(r io.ReadSeeker) (*asset, error) {
 	if err := b.Parse(); err != nil {
 		return nil, err
 	}
-	return &asset{bom: b}, nil
+	return New(b), nil
+}
+
+// NewWithReaderAt: asset of io.ReaderAt with size, safe for parallel use
+func NewWithReaderAt(r io.ReaderAt, size int64) (*asset, error) {
+	return NewWithOptions(r, size, bom.DefaultOptions)
+}
+
+// NewWithOptions: asset of io.ReaderAt with size and limits
+func NewWithOptions(r io.ReaderAt, size int64, opts bom.Options) (*asset, error) {
+	b := bom.NewWithOptions(r, size, opts)
+	if err := b.Parse(); err != nil {
+		return nil, err
+	}
+	return New(b), nil
 }
 
 func (a *asset) read(name string, order binary.ByteOrder, p interface{}) error {
@@ -76,29 +96,30 @@ func (a *asset) CarHeader() (*CarHeader, error) {
 }
 
 func (a *asset) KeyFormat() (*RenditionKeyFmt, error) {
-	buf, err := a.bom.ReadBlock("KEYFORMAT")
+	r, err := a.bom.ReadBlock("KEYFORMAT")
 	if err != nil {
 		return nil, err
 	}
-
-	c := &RenditionKeyFmt{}
-	if err := binary.Read(buf, binary.LittleEndian, &c.Tag); err != nil {
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
-	if err := binary.Read(buf, binary.LittleEndian, &c.Version); err != nil {
+	buf := helper.NewCursor(d, binary.LittleEndian)
+
+	c := &RenditionKeyFmt{}
+	buf.Fill(c.Tag[:])
+	c.Version = buf.Uint32()
+	c.MaximumRenditionKeyTokenCount = buf.Uint32()
+	if err := buf.Err(); err != nil {
 		return nil, err
 	}
-	if err := binary.Read(buf, binary.LittleEndian, &c.MaximumRenditionKeyTokenCount); err != nil {
-		return nil, err
+	// read key tokens, every token is 4 bytes
+	if uint64(c.MaximumRenditionKeyTokenCount)*4 > uint64(buf.Len()) {
+		return nil, io.ErrUnexpectedEOF
 	}
-	// read key tokens
 	c.RenditionKeyTokens = make([]RenditionAttributeType, c.MaximumRenditionKeyTokenCount)
-	for i := uint32(0); i < c.MaximumRenditionKeyTokenCount; i++ {
-		t := RenditionAttributeType(0)
-		if err := binary.Read(buf, binary.LittleEndian, &t); err != nil {
-			return nil, err
-		}
-		c.RenditionKeyTokens[i] = t
+	for i := range c.RenditionKeyTokens {
+		c.RenditionKeyTokens[i] = RenditionAttributeType(buf.Uint32())
 	}
 
 	return c, nil
@@ -115,10 +136,11 @@ func (a *asset) ExtendedMetadata() (*CarextendedMetadata, error) {
 func (a *asset) AppearanceKeys() (map[string]uint16, error) {
 	keys := map[string]uint16{}
 	if err := a.bom.ReadTree("APPEARANCEKEYS", func(k io.Reader, d io.Reader) error {
-		value := uint16(0)
-		if err := binary.Read(d, binary.BigEndian, &value); err != nil {
+		v := make([]byte, 2)
+		if _, err := io.ReadFull(d, v); err != nil {
 			return err
 		}
+		value := binary.BigEndian.Uint16(v)
 		key, err := ioutil.ReadAll(k)
 		if err != nil {
 			return err
@@ -134,21 +156,16 @@ func (a *asset) AppearanceKeys() (map[string]uint16, error) {
 func (a *asset) FacetKeys() (map[string]RenditionAttrs, error) {
 	data := map[string]RenditionAttrs{}
 	if err := a.bom.ReadTree("FACETKEYS", func(k io.Reader, d io.Reader) error {
-		attrs := map[RenditionAttributeType]uint16hex{}
-		t := &Renditionkeytoken{}
-		if err := binary.Read(d, binary.LittleEndian, &t.CursorHotSpot); err != nil {
+		v, err := ioutil.ReadAll(d)
+		if err != nil {
 			return err
 		}
-		if err := binary.Read(d, binary.LittleEndian, &t.NumberOfAttributes); err != nil {
+		t, err := decodeKeyToken(v)
+		if err != nil {
 			return err
 		}
-		t.Attributes = make([]RenditionAttribute, t.NumberOfAttributes)
-		for i := uint16(0); i < t.NumberOfAttributes; i++ {
-			a := RenditionAttribute{}
-			if err := binary.Read(d, binary.LittleEndian, &a); err != nil {
-				return err
-			}
-			t.Attributes[i] = a
+		attrs := map[RenditionAttributeType]uint16hex{}
+		for _, a := range t.Attributes {
 			attrs[RenditionAttributeType(a.Name)] = a.Value
 		}
 		name, err := ioutil.ReadAll(k)
@@ -163,23 +180,126 @@ func (a *asset) FacetKeys() (map[string]RenditionAttrs, error) {
 	return data, nil
 }
 
-func (a *asset) BitmapKeys() error {
+// facetNames: name of every identifier in FACETKEYS
+func (a *asset) facetNames() (map[uint16hex]string, error) {
+	facets, err := a.FacetKeys()
+	if err != nil {
+		return nil, err
+	}
+	names := map[uint16hex]string{}
+	for name, attrs := range facets {
+		if id, ok := attrs[kRenditionAttributeType_Identifier]; ok {
+			names[id] = name
+		}
+	}
+	return names, nil
+}
+
+// appearanceNames: name of every appearance value, empty if the catalog has no APPEARANCEKEYS
+func (a *asset) appearanceNames() (map[uint16hex]string, error) {
+	keys, err := a.AppearanceKeys()
+	if errors.Is(err, bom.ErrNameNotMatch) {
+		return map[uint16hex]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := map[uint16hex]string{}
+	for name, v := range keys {
+		names[uint16hex(v)] = name
+	}
+	return names, nil
+}
+
+// BitmapAttrs: bitmap of attribute values used by renditions of one name,
+// bit n is set if value n is used, 0xFFFFFFFF means any value
+type BitmapAttrs map[RenditionAttributeType]uint32
+
+// BitmapKeys: bitmaps of every name in BITMAPKEYS, by name of FACETKEYS
+// identifiers without facet are named by hex identifier, like "1AC1"
+func (a *asset) BitmapKeys() (map[string]BitmapAttrs, error) {
+	kf, err := a.KeyFormat()
+	if err != nil {
+		return nil, err
+	}
+	names, err := a.facetNames()
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]BitmapAttrs{}
 	if err := a.bom.ReadTree("BITMAPKEYS", func(k io.Reader, d io.Reader) error {
-		// TODO: handle bitmapKeys
-		kk, err := ioutil.ReadAll(k)
-		if err != nil {
+		kd := make([]byte, 4)
+		if _, err := io.ReadFull(k, kd); err != nil {
 			return err
 		}
-		dd, err := ioutil.ReadAll(d)
+		id := binary.BigEndian.Uint32(kd)
+		key, err := decodeBitmapKey(d)
 		if err != nil {
-			return err
+			return fmt.Errorf("bitmap key %04X: %w", id, err)
 		}
-		log.Printf("%v: %v", kk, dd)
+		if int(key.Count) > len(kf.RenditionKeyTokens) {
+			return fmt.Errorf("bitmap key %04X: %d bitmaps, KEYFORMAT has %d tokens", id, key.Count, len(kf.RenditionKeyTokens))
+		}
+		attrs := BitmapAttrs{}
+		for i, v := range key.Bitmaps {
+			attrs[kf.RenditionKeyTokens[i]] = v
+		}
+		name, ok := names[uint16hex(id)]
+		if !ok || id > 0xffff {
+			name = fmt.Sprintf("%04X", id)
+		}
+		data[name] = attrs
 		return nil
 	}); err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return data, nil
+}
+
+func decodeKeyToken(d []byte) (*Renditionkeytoken, error) {
+	c := helper.NewCursor(d, binary.LittleEndian)
+	t := &Renditionkeytoken{}
+	t.CursorHotSpot.X = c.Uint16()
+	t.CursorHotSpot.Y = c.Uint16()
+	t.NumberOfAttributes = c.Uint16()
+	if c.Err() != nil {
+		return nil, c.Err()
+	}
+	// every attribute is 4 bytes
+	if int(t.NumberOfAttributes)*4 > c.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	t.Attributes = make([]RenditionAttribute, t.NumberOfAttributes)
+	for i := range t.Attributes {
+		t.Attributes[i] = RenditionAttribute{Name: c.Uint16(), Value: uint16hex(c.Uint16())}
+	}
+	return t, nil
+}
+
+func decodeBitmapKey(r io.Reader) (*BitmapKey, error) {
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(d) < 16 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	key := &BitmapKey{
+		Version:  binary.LittleEndian.Uint32(d[0:]),
+		Unknown0: binary.LittleEndian.Uint32(d[4:]),
+		Length:   binary.LittleEndian.Uint32(d[8:]),
+		Count:    binary.LittleEndian.Uint32(d[12:]),
+	}
+	d = d[16:]
+	if uint64(key.Count)*4 > uint64(len(d)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	key.Bitmaps = make([]uint32, key.Count)
+	for i := range key.Bitmaps {
+		key.Bitmaps[i] = binary.LittleEndian.Uint32(d[i*4:])
+	}
+	return key, nil
 }
 
 type RenditionAttrs map[RenditionAttributeType]uint16hex
@@ -190,14 +310,33 @@ const (
 	RenditionTypeImage = RenditionType(0)
 	RenditionTypeData  = RenditionType(1)
 	RenditionTypeColor = RenditionType(3)
+	// PDF or SVG
+	RenditionTypeVector = RenditionType(4)
+	// sizes of image set, images of sizes are RenditionTypeImage
+	RenditionTypeMultisize = RenditionType(5)
 )
 
+// errStop: stop walking tree, never returned to caller
+var errStop = errors.New("stop")
+
 type RenditionCallback struct {
 	Attrs RenditionAttrs
 	Type  RenditionType
 	Err   error
 	Image image.Image
-	Name  string
+	// encoded bytes of JPEG and HEIF images, HEIF has Err ErrNotDecoded and no Image
+	Encoded *EncodedImage
+	// PDF or SVG of RenditionTypeVector
+	Vector *VectorRendition
+	// sizes of RenditionTypeMultisize
+	Multisize *MultisizeImageSet
+	// color of RenditionTypeColor
+	Color *ColorRendition
+	// data of RenditionTypeData, it can be read after callback returns, while the file is open
+	Data io.Reader
+	// uniform type identifier from TLV, like "public.json"
+	UTI  string
+	Name string
 }
 
 func (a *asset) Renditions(loop func(cb *RenditionCallback) (stop bool)) error {
@@ -207,78 +346,259 @@ func (a *asset) Renditions(loop func(cb *RenditionCallback) (stop bool)) error {
 	}
 
 	if err := a.bom.ReadTree("RENDITIONS", func(k io.Reader, d io.Reader) error {
-		attrs := RenditionAttrs{}
-		for i := 0; i < len(kf.RenditionKeyTokens); i++ {
-			v := uint16hex(0)
-			binary.Read(k, binary.LittleEndian, &v)
-			attrs[kf.RenditionKeyTokens[i]] = v
+		key, err := ioutil.ReadAll(k)
+		if err != nil {
+			return &RenditionError{Err: fmt.Errorf("read key: %w", err)}
 		}
-
-		c := &csiheader{}
-		if err := binary.Read(d, binary.LittleEndian, c); err != nil {
+		attrs, err := keyAttrs(kf, key)
+		if err != nil {
 			return err
 		}
-
-		// TODO: skip TLV for now
-		tmp := make([]byte, c.Csibitmaplist.TvlLength)
-		if _, err := d.Read(tmp); err != nil {
+		cb, err := a.decodeRendition(attrs, d, nil)
+		if err != nil {
 			return err
 		}
+		if cb != nil && loop(cb) {
+			return errStop
+		}
+		return nil
+	}); err != nil && err != errStop {
+		return err
+	}
+	return nil
+}
 
-		// log.Printf("%s: %s: %s attrs: %+v TVL: %+v %v", c.Tag.String(), c.PixelFormat.String(), c.Csimetadata.Name.String(), attrs, c, len(tmp))
-		// string value reverse
-		format := strings.TrimSpace(string(helper.Reverse(c.PixelFormat[:])))
-		switch format {
-		case "DATA":
-			// TODO:
-			log.Print("TODO: handle DATA")
-		case "JPEG", "HEIF":
-			// TODO:
-			log.Print("TODO: handle JPEG")
-		case "ARGB", "GA8", "RGB5", "RGBW", "GA16":
-			// TODO:
-			cb := &RenditionCallback{
-				Attrs: attrs,
-				Type:  RenditionTypeImage,
-				Name:  c.Csimetadata.Name.String(),
-			}
+// keyAttrs: attribute values of RENDITIONS key, packed in KEYFORMAT order
+func keyAttrs(kf *RenditionKeyFmt, key []byte) (RenditionAttrs, error) {
+	kc := helper.NewCursor(key, binary.LittleEndian)
+	attrs := RenditionAttrs{}
+	for _, t := range kf.RenditionKeyTokens {
+		attrs[t] = uint16hex(kc.Uint16())
+	}
+	if err := kc.Err(); err != nil {
+		return nil, &RenditionError{Err: fmt.Errorf("read key: %w", err)}
+	}
+	return attrs, nil
+}
 
-			img, err := a.decodeImage(format, d, c)
-			if err != nil {
-				cb.Err = err
-				stop := loop(cb)
-				if stop {
-					return err
-				}
-			}
-			cb.Image = img
-			stop := loop(cb)
-			if stop {
-				return nil
+// renditionType: type of rendition by pixel format and layout, false for layouts without decoder
+func renditionType(format string, c *csiheader) (RenditionType, bool, error) {
+	if isVector(format, c) {
+		return RenditionTypeVector, true, nil
+	}
+	switch format {
+	case "DATA":
+		return RenditionTypeData, true, nil
+	case "JPEG", "HEIF", "ARGB", "GA8", "RGB5", "RGBW", "GA16":
+		return RenditionTypeImage, true, nil
+	case string([]byte{0, 0, 0, 0}):
+		switch c.Csimetadata.Layout {
+		case kRenditionLayoutType_Color:
+			return RenditionTypeColor, true, nil
+		case kRenditionLayoutType_MultisizeImage:
+			return RenditionTypeMultisize, true, nil
+		}
+		return 0, false, nil
+	}
+	return 0, false, &RenditionError{Name: c.Csimetadata.Name.String(), Format: c.PixelFormat.String(), Err: ErrUnsupportedPixelFormat}
+}
+
+// decodeRendition: decode value d of RENDITIONS entry, nil for layouts without decoder,
+// and for types not accepted by want, those are not read past csi header and TLV, nil want accepts every type
+// errors of payload are set to Err of callback, errors of header are returned
+func (a *asset) decodeRendition(attrs RenditionAttrs, d io.Reader, want func(t RenditionType) bool) (*RenditionCallback, error) {
+	c, err := readCSIHeader(d)
+	if err != nil {
+		return nil, &RenditionError{Err: fmt.Errorf("read csi header: %w", err)}
+	}
+	name := c.Csimetadata.Name.String()
+
+	tlvData, err := readN(d, int64(c.Csibitmaplist.TvlLength))
+	if err != nil {
+		return nil, &RenditionError{Name: name, Err: fmt.Errorf("read TLV: %w", err)}
+	}
+	tlvs := decodeTLV(tlvData)
+
+	// string value reverse
+	format := strings.TrimSpace(string(helper.Reverse(c.PixelFormat[:])))
+	t, ok, err := renditionType(format, c)
+	if err != nil || !ok || (want != nil && !want(t)) {
+		return nil, err
+	}
+
+	cb := &RenditionCallback{Attrs: attrs, Type: t, Name: name}
+	switch t {
+	case RenditionTypeVector:
+		cb.Vector, err = a.readVector(format, d, c, attrs)
+		if err != nil {
+			cb.Err = &RenditionError{Name: name, Format: strings.Trim(format, "\x00"), Err: err}
+		}
+	case RenditionTypeData:
+		cb.UTI = decodeUTI(tlvs[kRenditionTLVType_UTI])
+		cb.Data, err = decodeRawData(d, c.Csibitmaplist.RenditionLength)
+		if err != nil {
+			cb.Err = &RenditionError{Name: name, Format: format, Err: err}
+		}
+	case RenditionTypeImage:
+		if format == "JPEG" || format == "HEIF" {
+			cb.Encoded, err = a.readEncoded(format, d)
+			if err == nil {
+				cb.Image, err = decodeEncoded(cb.Encoded)
 			}
-		case string([]byte{0, 0, 0, 0}):
-			switch c.Csimetadata.Layout {
-			case kRenditionLayoutType_Color:
-				// TODO:
-			case kRenditionLayoutType_MultisizeImage:
-				// _CUIThemeMultisizeImageSetRendition
-				// TODO:
-				p := CUIThemeMultisizeImageSetRendition{}
-				if err := binary.Read(d, binary.LittleEndian, &p); err != nil {
-					return err
-				}
+		} else {
+			cb.Image, err = a.decodeImage(format, d, c)
+		}
+		if err != nil {
+			cb.Err = &RenditionError{Name: name, Format: format, Err: err}
+		}
+	case RenditionTypeColor:
+		cb.Color, err = decodeColor(d)
+		if err != nil {
+			cb.Err = &RenditionError{Name: name, Err: fmt.Errorf("read color: %w", err)}
+		}
+	case RenditionTypeMultisize:
+		cb.Multisize, err = decodeMultisize(d)
+		if err != nil {
+			cb.Err = &RenditionError{Name: name, Err: fmt.Errorf("read multisize image set: %w", err)}
+		}
+	}
+	return cb, nil
+}
+
+// facet: attributes of renditions of name, found in FACETKEYS with Lookup, keys of FACETKEYS are sorted names
+// FACETKEYS is read whole when the parser is not a bom.BomStore, or Lookup does not find name
+// only the identifier is kept, Element and Part of facet may differ from those of renditions,
+// like multisize image sets of app icons
+func (a *asset) facet(name string) (RenditionAttrs, error) {
+	s, ok := a.bom.(bom.BomStore)
+	if !ok {
+		return a.scanFacet(name)
+	}
+
+	r, err := s.Lookup("FACETKEYS", []byte(name), nil)
+	if errors.Is(err, bom.ErrKeyNotFound) {
+		// Lookup expects keys in byte order, other writers may not sort them
+		return a.scanFacet(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tk, err := decodeKeyToken(d)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range tk.Attributes {
+		if RenditionAttributeType(v.Name) == kRenditionAttributeType_Identifier {
+			return RenditionAttrs{kRenditionAttributeType_Identifier: v.Value}, nil
+		}
+	}
+	return nil, &RenditionError{Name: name, Err: ErrNotFound}
+}
+
+// scanFacet: identifier of facet name, found by reading every FACETKEYS entry
+func (a *asset) scanFacet(name string) (RenditionAttrs, error) {
+	facets, err := a.FacetKeys()
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := facets[name][kRenditionAttributeType_Identifier]; ok {
+		return RenditionAttrs{kRenditionAttributeType_Identifier: id}, nil
+	}
+	return nil, &RenditionError{Name: name, Err: ErrNotFound}
+}
+
+// renditionsOf: renditions of type t whose keys have the values of attrs, like attributes of facet,
+// attributes not in KEYFORMAT are ignored, empty attrs match every key
+// keys are matched as bytes before values are read, RENDITIONS is scanned because attrs are not a prefix of keys,
+// like Identifier after Scale and Idiom in KEYFORMAT,
+// only matching renditions of type t are decoded
+func (a *asset) renditionsOf(attrs RenditionAttrs, t RenditionType, loop func(cb *RenditionCallback) (stop bool)) error {
+	kf, err := a.KeyFormat()
+	if err != nil {
+		return err
+	}
+	// key bytes of fixed attributes, at offset of their token
+	want := []byte{}
+	offsets := []int{}
+	for i, tk := range kf.RenditionKeyTokens {
+		if v, ok := attrs[tk]; ok {
+			want = append(want, byte(v), byte(v>>8))
+			offsets = append(offsets, i*2)
+		}
+	}
+	match := func(key []byte) bool {
+		for i, off := range offsets {
+			if off+2 > len(key) || key[off] != want[i*2] || key[off+1] != want[i*2+1] {
+				return false
 			}
-		default:
-			return fmt.Errorf("unknown rendition with pixel format: %v", c.PixelFormat.String())
 		}
+		return true
+	}
+	isType := func(v RenditionType) bool { return v == t }
 
+	if err := a.bom.ReadTree("RENDITIONS", func(k io.Reader, d io.Reader) error {
+		key, err := ioutil.ReadAll(k)
+		if err != nil {
+			return &RenditionError{Err: fmt.Errorf("read key: %w", err)}
+		}
+		if !match(key) {
+			return nil
+		}
+		keyAttrs, err := keyAttrs(kf, key)
+		if err != nil {
+			return err
+		}
+		cb, err := a.decodeRendition(keyAttrs, d, isType)
+		if err != nil {
+			return err
+		}
+		if cb != nil && loop(cb) {
+			return errStop
+		}
 		return nil
-	}); err != nil {
+	}); err != nil && err != errStop {
 		return err
 	}
 	return nil
 }
 
+// csiHeaderSize: bytes of csiheader
+const csiHeaderSize = 184
+
+func readCSIHeader(r io.Reader) (*csiheader, error) {
+	d := make([]byte, csiHeaderSize)
+	if _, err := io.ReadFull(r, d); err != nil {
+		return nil, err
+	}
+	return decodeCSIHeader(d)
+}
+
+func decodeCSIHeader(d []byte) (*csiheader, error) {
+	c := helper.NewCursor(d, binary.LittleEndian)
+	h := &csiheader{}
+	c.Fill(h.Tag[:])
+	h.Version = c.Uint32()
+	h.RenditionFlags = renditionFlags(c.Uint32())
+	h.Width = c.Uint32()
+	h.Height = c.Uint32()
+	h.ScaleFactor = c.Uint32()
+	c.Fill(h.PixelFormat[:])
+	h.ColorSpace = colorSpace(c.Uint32())
+	h.Csimetadata.Modtime = c.Uint32()
+	h.Csimetadata.Layout = RenditionLayoutType(c.Uint16())
+	h.Csimetadata.Zero = c.Uint16()
+	c.Fill(h.Csimetadata.Name[:])
+	h.Csibitmaplist.TvlLength = c.Uint32()
+	h.Csibitmaplist.Unknown = c.Uint32()
+	h.Csibitmaplist.Zero = c.Uint32()
+	h.Csibitmaplist.RenditionLength = c.Uint32()
+	return h, c.Err()
+}
+
 func (a *asset) ImageWalker(loop func(name string, img image.Image) (end bool)) error {
 	c, err := a.FacetKeys()
 	if err != nil {
@@ -297,13 +617,10 @@ func (a *asset) ImageWalker(loop func(name string, img image.Image) (end bool))
 		}
 		idMap[id] = &item{name: k, attrs: v}
 	}
-	return a.Renditions(func(cb *RenditionCallback) (stop bool) {
+	return a.renditionsOf(nil, RenditionTypeImage, func(cb *RenditionCallback) (stop bool) {
 		if cb.Err != nil {
 			return false
 		}
-		if cb.Type != RenditionTypeImage {
-			return false
-		}
 		id, ok := cb.Attrs[kRenditionAttributeType_Identifier]
 		if !ok {
 			return false
@@ -319,17 +636,33 @@ func (a *asset) ImageWalker(loop func(name string, img image.Image) (end bool))
 	})
 }
 
+// Image: decode first image of name,
+// returns the decode error of the image if there is no image decoded
 func (a *asset) Image(name string) (image.Image, error) {
+	attrs, err := a.facet(name)
+	if err != nil {
+		return nil, err
+	}
+
 	var img image.Image
-	err := a.ImageWalker(func(n string, i image.Image) (end bool) {
-		if name == n {
-			img = i
-			return true
+	var decodeErr error
+	if err := a.renditionsOf(attrs, RenditionTypeImage, func(cb *RenditionCallback) (stop bool) {
+		if cb.Err != nil {
+			if decodeErr == nil {
+				decodeErr = cb.Err
+			}
+			return false
 		}
-		return false
-	})
-	if img == nil {
-		return nil, fmt.Errorf("not found: %v", name)
+		img = cb.Image
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	if img != nil {
+		return img, nil
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
 	}
-	return img, err
+	return nil, &RenditionError{Name: name, Err: ErrNotFound}
 }
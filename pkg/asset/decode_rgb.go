@@ -12,6 +12,8 @@ import (
 	"io/ioutil"
 
 	lzfse "github.com/blacktop/lzfse-cgo"
+	"github.com/iineva/bom/pkg/bom"
+	"github.com/iineva/bom/pkg/helper"
 	"github.com/iineva/bom/pkg/mreader"
 )
 
@@ -64,65 +66,117 @@ func (p *GA8) PixOffset(x, y int) int {
 
 // format: "ARGB", "GA8", "RGB5", "RGBW", "GA16"
 func (a *asset) decodeImage(format string, d io.Reader, c *csiheader) (image.Image, error) {
-	p := &CUIThemePixelRendition{}
-	if err := binary.Read(d, binary.LittleEndian, &p.Tag); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(d, binary.LittleEndian, &p.Version); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(d, binary.LittleEndian, &p.CompressionType); err != nil {
+	h := make([]byte, 16)
+	if _, err := io.ReadFull(d, h); err != nil {
 		return nil, err
 	}
-	if err := binary.Read(d, binary.LittleEndian, &p.RawDataLength); err != nil {
-		return nil, err
+	hc := helper.NewCursor(h, binary.LittleEndian)
+	p := &CUIThemePixelRendition{}
+	hc.Fill(p.Tag[:])
+	p.Version = hc.Uint32()
+	p.CompressionType = RenditionCompressionType(hc.Uint32())
+	p.RawDataLength = hc.Uint32()
+
+	if v := int64(c.Width) * int64(c.Height); v > a.opts.MaxPixels {
+		return nil, &bom.LimitError{Limit: "MaxPixels", Value: v, Max: a.opts.MaxPixels}
 	}
 
 	rawData := mreader.New()
+	// decompressed bytes can still be read
+	remain := a.opts.MaxDecompressedBytes
 
 	// decode header
 	switch p.Version {
 	case 0, 2:
-		buf := make([]byte, p.RawDataLength)
-		if _, err := d.Read(buf); err != nil {
+		buf, err := readN(d, int64(p.RawDataLength))
+		if err != nil {
 			return nil, err
 		}
 		r, err := umCompression(p.CompressionType, bytes.NewBuffer(buf))
 		if err != nil {
 			return nil, err
 		}
-		rawData.Add(r)
+		raw, err := readLimit(r, remain)
+		if err != nil {
+			return nil, err
+		}
+		rawData.Add(io.NopCloser(bytes.NewReader(raw)))
 	case 1, 3:
+		h := make([]byte, 20)
 		for i := 0; i < int(p.RawDataLength); i++ {
-			v3 := &CUIThemePixelRenditionV3{}
-			if err := binary.Read(d, binary.LittleEndian, v3); err != nil {
+			if _, err := io.ReadFull(d, h); err != nil {
 				return nil, err
 			}
+			v3 := decodePixelRenditionV3(h)
 			buf := make([]byte, v3.RowDataLen)
-			err := binary.Read(d, binary.LittleEndian, buf)
-			if err != nil {
+			if _, err := io.ReadFull(d, buf); err != nil {
 				return nil, err
 			}
 			r, err := umCompression(p.CompressionType, bytes.NewBuffer(buf))
 			if err != nil {
 				return nil, err
 			}
-			rawData.Add(r)
+			raw, err := readLimit(r, remain)
+			if err != nil {
+				return nil, err
+			}
+			remain -= int64(len(raw))
+			rawData.Add(io.NopCloser(bytes.NewReader(raw)))
 		}
 	default:
-		return nil, fmt.Errorf("unsupport version: %v", p.Version)
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedVersion, p.Version)
 	}
 
 	defer rawData.Close()
 	return decodeImage(format, int(c.Width), int(c.Height), rawData)
 }
 
+// decodePixelRenditionV3: header of one row block, h is 20 bytes
+func decodePixelRenditionV3(h []byte) *CUIThemePixelRenditionV3 {
+	c := helper.NewCursor(h, binary.LittleEndian)
+	return &CUIThemePixelRenditionV3{
+		Arg1:       c.Uint16(),
+		Arg2:       c.Uint16(),
+		Arg3:       c.Uint32(),
+		Arg4:       c.Uint32(),
+		Height:     c.Uint32(),
+		RowDataLen: c.Uint16(),
+		Arg6:       c.Uint16(),
+	}
+}
+
+// readN: read n bytes, memory grows with bytes really read instead of n
+func readN(r io.Reader, n int64) ([]byte, error) {
+	d, err := ioutil.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(d)) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return d, nil
+}
+
+// readLimit: read all decompressed bytes, no more than max
+func readLimit(r io.ReadCloser, max int64) ([]byte, error) {
+	defer r.Close()
+	d, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(d)) > max {
+		return nil, &bom.LimitError{Limit: "MaxDecompressedBytes", Value: int64(len(d)), Max: max}
+	}
+	return d, nil
+}
+
 func umCompression(t RenditionCompressionType, r io.Reader) (decoded io.ReadCloser, err error) {
 	// upcompression raw data
 	switch t {
 	case kRenditionCompressionType_zip:
 		return gzip.NewReader(r)
 	case kRenditionCompressionType_lzfse:
+		// NOTE: lzfse-cgo grows its output buffer to about 100MB at most
 		d, err := ioutil.ReadAll(r)
 		if err != nil {
 			return nil, err
@@ -134,7 +188,7 @@ func umCompression(t RenditionCompressionType, r io.Reader) (decoded io.ReadClos
 	// TODO
 	// case kRenditionCompressionType_deepmap_2:
 	default:
-		return nil, fmt.Errorf("unsupport compression type: %v", t)
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedCompression, t)
 	}
 	return
 }
@@ -192,5 +246,5 @@ func decodeImage(format string, width, height int, r io.Reader) (image.Image, er
 	case "RGBW":
 	case "GA16":
 	}
-	return nil, fmt.Errorf("unsupport image format: %v", format)
+	return nil, fmt.Errorf("%w: %v", ErrUnsupportedPixelFormat, format)
 }
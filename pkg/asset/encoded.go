@@ -0,0 +1,210 @@
+package asset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+
+	"github.com/iineva/bom/pkg/bom"
+	"github.com/iineva/bom/pkg/helper"
+)
+
+var (
+	// ErrNotDecoded: image is only available as encoded bytes, like HEIF, see EncodedImage
+	ErrNotDecoded  = errors.New("image is not decoded")
+	ErrNoImageSize = errors.New("image size not found")
+)
+
+// EncodedImage: JPEG or HEIF bytes of rendition, as stored in catalog
+type EncodedImage struct {
+	// "JPEG" or "HEIF"
+	Format string
+	// size from JPEG header or 'ispe' box of HEIF
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// EncodedImage: JPEG or HEIF bytes of first JPEG or HEIF rendition of name
+func (a *asset) EncodedImage(name string) (*EncodedImage, error) {
+	attrs, err := a.facet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var img *EncodedImage
+	var decodeErr error
+	if err := a.renditionsOf(attrs, RenditionTypeImage, func(cb *RenditionCallback) (stop bool) {
+		if cb.Encoded != nil {
+			img = cb.Encoded
+			return true
+		}
+		if cb.Err != nil && decodeErr == nil {
+			decodeErr = cb.Err
+		}
+		return false
+	}); err != nil {
+		return nil, err
+	}
+	if img != nil {
+		return img, nil
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return nil, &RenditionError{Name: name, Err: ErrNotFound}
+}
+
+// readEncoded: encoded bytes of JPEG or HEIF rendition with size
+func (a *asset) readEncoded(format string, d io.Reader) (*EncodedImage, error) {
+	data, err := a.readPayload(d)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &EncodedImage{Format: format, Data: data}
+	switch format {
+	case "JPEG":
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		img.Width, img.Height = cfg.Width, cfg.Height
+	case "HEIF":
+		w, h, err := heifSize(data)
+		if err != nil {
+			return nil, err
+		}
+		img.Width, img.Height = w, h
+	}
+	if v := int64(img.Width) * int64(img.Height); v > a.opts.MaxPixels {
+		return nil, &bom.LimitError{Limit: "MaxPixels", Value: v, Max: a.opts.MaxPixels}
+	}
+	return img, nil
+}
+
+// readPayload: bytes of rendition stored as raw data 'RAWD',
+// or as pixel rendition 'CELM' compressed with lzfse or jpeg_lzfse
+func (a *asset) readPayload(d io.Reader) ([]byte, error) {
+	h := make([]byte, 16)
+	if _, err := io.ReadFull(d, h[:12]); err != nil {
+		return nil, err
+	}
+	c := helper.NewCursor(h, binary.LittleEndian)
+	tag := helper.String4{}
+	c.Fill(tag[:])
+	c.Skip(4) // version
+
+	switch tag.String() {
+	case "DWAR": // 'RAWD'
+		n := int64(c.Uint32())
+		if n > a.opts.MaxDecompressedBytes {
+			return nil, &bom.LimitError{Limit: "MaxDecompressedBytes", Value: n, Max: a.opts.MaxDecompressedBytes}
+		}
+		return readN(d, n)
+	case "MLEC": // 'CELM'
+		if _, err := io.ReadFull(d, h[12:]); err != nil {
+			return nil, err
+		}
+		compression := RenditionCompressionType(c.Uint32())
+		n := int64(c.Uint32())
+		if n > a.opts.MaxDecompressedBytes {
+			return nil, &bom.LimitError{Limit: "MaxDecompressedBytes", Value: n, Max: a.opts.MaxDecompressedBytes}
+		}
+		raw, err := readN(d, n)
+		if err != nil {
+			return nil, err
+		}
+		// jpeg_lzfse is the encoded image compressed again with lzfse
+		if compression == kRenditionCompressionType_jpeg_lzfse {
+			compression = kRenditionCompressionType_lzfse
+		}
+		r, err := umCompression(compression, bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		return readLimit(r, a.opts.MaxDecompressedBytes)
+	default:
+		return nil, fmt.Errorf("%w: tag '%s'", ErrUnsupportedVersion, tag.String())
+	}
+}
+
+// decodeEncoded: decode JPEG, HEIF is returned with ErrNotDecoded
+func decodeEncoded(e *EncodedImage) (image.Image, error) {
+	if e.Format != "JPEG" {
+		return nil, ErrNotDecoded
+	}
+	return jpeg.Decode(bytes.NewReader(e.Data))
+}
+
+// heifSize: largest size of 'ispe' boxes in meta/iprp/ipco,
+// grid images have an 'ispe' for every tile and one for the whole image
+func heifSize(d []byte) (int, int, error) {
+	width, height := 0, 0
+	var walk func(d []byte, path []string) error
+	walk = func(d []byte, path []string) error {
+		for len(d) > 0 {
+			typ, body, rest, err := nextBox(d)
+			if err != nil {
+				return err
+			}
+			d = rest
+			switch {
+			case len(path) == 0 && typ == "meta":
+				// full box, version and flags before children
+				if len(body) < 4 {
+					return io.ErrUnexpectedEOF
+				}
+				if err := walk(body[4:], []string{"meta"}); err != nil {
+					return err
+				}
+			case len(path) == 1 && typ == "iprp", len(path) == 2 && typ == "ipco":
+				if err := walk(body, append(path, typ)); err != nil {
+					return err
+				}
+			case len(path) == 3 && typ == "ispe":
+				c := helper.NewCursor(body, binary.BigEndian)
+				c.Skip(4) // version and flags
+				w, h := int(c.Uint32()), int(c.Uint32())
+				if c.Err() != nil {
+					return c.Err()
+				}
+				if int64(w)*int64(h) > int64(width)*int64(height) {
+					width, height = w, h
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(d, nil); err != nil {
+		return 0, 0, err
+	}
+	if width == 0 || height == 0 {
+		return 0, 0, ErrNoImageSize
+	}
+	return width, height, nil
+}
+
+// nextBox: first ISO BMFF box of d, size 1 is 64 bit size, size 0 is to the end of d
+func nextBox(d []byte) (typ string, body, rest []byte, err error) {
+	c := helper.NewCursor(d, binary.BigEndian)
+	size := uint64(c.Uint32())
+	typ = string(c.Bytes(4))
+	switch size {
+	case 0:
+		size = uint64(len(d))
+	case 1:
+		size = c.Uint64()
+	}
+	if c.Err() != nil {
+		return "", nil, nil, c.Err()
+	}
+	if size < uint64(c.Offset()) || size > uint64(len(d)) {
+		return "", nil, nil, io.ErrUnexpectedEOF
+	}
+	return typ, d[c.Offset():size], d[size:], nil
+}
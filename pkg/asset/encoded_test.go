@@ -0,0 +1,73 @@
+package asset
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+func TestEncodedImage(t *testing.T) {
+	jpg := testJPEG(t, 8, 4)
+	a := testCatalog(t)
+
+	for _, name := range []string{"raw", "lzfse"} {
+		img, err := a.Image(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 8, 4) {
+			t.Fatalf("%s: %v", name, img.Bounds())
+		}
+		if r, g, _, _ := img.At(0, 0).RGBA(); r < 0xc000 || g > 0x4000 {
+			t.Fatalf("%s: pixel %v", name, img.At(0, 0))
+		}
+		enc, err := a.EncodedImage(name)
+		if err != nil || enc.Format != "JPEG" || !bytes.Equal(enc.Data, jpg) || enc.Width != 8 || enc.Height != 4 {
+			t.Fatalf("%s: encoded %v", name, err)
+		}
+	}
+
+	if _, err := a.Image("heif"); !errors.Is(err, ErrNotDecoded) {
+		t.Fatalf("heif image: %v", err)
+	}
+	enc, err := a.EncodedImage("heif")
+	if err != nil || enc.Format != "HEIF" || enc.Width != 64 || enc.Height != 32 || !bytes.Equal(enc.Data, testHEIF(64, 32)) {
+		t.Fatalf("heif: %+v %v", enc, err)
+	}
+
+	var re *RenditionError
+	if _, err := a.EncodedImage("broken.jpg"); !errors.As(err, &re) || re.Format != "JPEG" {
+		t.Fatalf("broken: %v", err)
+	}
+	if _, err := a.EncodedImage("config"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("data is not image: %v", err)
+	}
+
+	// limits are checked before decoding
+	limited := New(a.bom)
+	limited.opts.MaxPixels = 16
+	if _, err := limited.Image("raw"); !errors.Is(err, bom.ErrLimitExceeded) {
+		t.Fatalf("limit: %v", err)
+	}
+}
+
+func TestHEIFSize(t *testing.T) {
+	if w, h, err := heifSize(testHEIF(4032, 3024)); err != nil || w != 4032 || h != 3024 {
+		t.Fatalf("size: %d %d %v", w, h, err)
+	}
+	if _, _, err := heifSize(box("ftyp", []byte("heic"))); !errors.Is(err, ErrNoImageSize) {
+		t.Fatalf("no ispe: %v", err)
+	}
+	d := testHEIF(10, 10)
+	if _, _, err := heifSize(d[:len(d)-20]); err == nil {
+		t.Fatal("truncated: no error")
+	}
+	// 64 bit box size
+	large := append([]byte{0, 0, 0, 1, 'f', 'r', 'e', 'e', 0, 0, 0, 0, 0, 0, 0, 16}, testHEIF(5, 6)...)
+	if w, h, err := heifSize(large); err != nil || w != 5 || h != 6 {
+		t.Fatalf("large size: %d %d %v", w, h, err)
+	}
+}
@@ -0,0 +1,37 @@
+package asset
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound               = errors.New("not found")
+	ErrUnsupportedCompression = errors.New("unsupported compression type")
+	ErrUnsupportedPixelFormat = errors.New("unsupported pixel format")
+	ErrUnsupportedVersion     = errors.New("unsupported version")
+)
+
+// RenditionError: error of decoding one rendition
+type RenditionError struct {
+	// rendition name from csi header, or name asked for
+	Name string
+	// pixel format, like "ARGB"
+	Format string
+	Err    error
+}
+
+func (e *RenditionError) Error() string {
+	s := "asset: rendition"
+	if e.Name != "" {
+		s += fmt.Sprintf(" '%s'", e.Name)
+	}
+	if e.Format != "" {
+		s += fmt.Sprintf(" format %s", e.Format)
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *RenditionError) Unwrap() error {
+	return e.Err
+}
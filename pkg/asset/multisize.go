@@ -0,0 +1,161 @@
+package asset
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/iineva/bom/pkg/helper"
+)
+
+// MultisizeImageSize: one size of multisize image set, an entry of sizes of 'SISM'
+type MultisizeImageSize struct {
+	// uint32_t width; // points
+	Width uint32
+	// uint32_t height;
+	Height uint32
+	// uint16_t index; // kRenditionAttributeType_Dimension2 of renditions of this size
+	Index uint16
+	// uint16_t idiom;
+	Idiom uint16
+}
+
+// MultisizeImageSet: rendition of kRenditionLayoutType_MultisizeImage, like sizes of app icon,
+// CUIThemeMultisizeImageSetRendition of CoreUI
+type MultisizeImageSet struct {
+	// uint32_t tag; // 'SISM'
+	Tag helper.String4
+	// uint32_t version;
+	Version uint32
+	// uint32_t sizesCount;
+	SizesCount uint32
+	// struct { uint32_t width; uint32_t height; uint16_t index; uint16_t idiom; } sizes[];
+	Sizes []MultisizeImageSize
+
+	// facet name and key of rendition, set by MultisizeImageSets
+	Name  string
+	Attrs RenditionAttrs
+}
+
+func decodeMultisize(r io.Reader) (*MultisizeImageSet, error) {
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c := helper.NewCursor(d, binary.LittleEndian)
+	s := &MultisizeImageSet{}
+	c.Fill(s.Tag[:])
+	s.Version = c.Uint32()
+	s.SizesCount = c.Uint32()
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	// every size is 12 bytes
+	if uint64(s.SizesCount)*12 > uint64(c.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	s.Sizes = make([]MultisizeImageSize, s.SizesCount)
+	for i := range s.Sizes {
+		s.Sizes[i] = MultisizeImageSize{
+			Width:  c.Uint32(),
+			Height: c.Uint32(),
+			Index:  c.Uint16(),
+			Idiom:  c.Uint16(),
+		}
+	}
+	return s, nil
+}
+
+// MultisizeImageSets: multisize image sets of name, one for each idiom and subtype
+func (a *asset) MultisizeImageSets(name string) ([]*MultisizeImageSet, error) {
+	attrs, err := a.facet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*MultisizeImageSet{}
+	var setErr error
+	if err := a.renditionsOf(attrs, RenditionTypeMultisize, func(cb *RenditionCallback) (stop bool) {
+		if cb.Err != nil {
+			setErr = cb.Err
+			return true
+		}
+		s := cb.Multisize
+		s.Name, s.Attrs = name, cb.Attrs
+		list = append(list, s)
+		return false
+	}); err != nil {
+		return nil, err
+	}
+	if setErr != nil {
+		return nil, setErr
+	}
+	if len(list) == 0 {
+		return nil, &RenditionError{Name: name, Err: ErrNotFound}
+	}
+	return list, nil
+}
+
+// MultisizeImage: image rendition of one size of multisize image set
+type MultisizeImage struct {
+	MultisizeImageSize
+	// file name in catalog
+	FileName string
+	// 1 for @1x, 2 for @2x, 3 for @3x
+	Scale uint16
+	Attrs RenditionAttrs
+	Image image.Image
+}
+
+// ResolveMultisize: image renditions of every size of s, sorted by size and scale,
+// renditions of a size have the same identifier and idiom as s, and index as Dimension2,
+// a size has one image for every scale, sizes without images are left out
+func (a *asset) ResolveMultisize(s *MultisizeImageSet) ([]*MultisizeImage, error) {
+	sizes := map[uint16hex]MultisizeImageSize{}
+	for _, v := range s.Sizes {
+		sizes[uint16hex(v.Index)] = v
+	}
+	attrs := RenditionAttrs{
+		kRenditionAttributeType_Identifier: s.Attrs[kRenditionAttributeType_Identifier],
+		kRenditionAttributeType_Idiom:      s.Attrs[kRenditionAttributeType_Idiom],
+	}
+
+	list := []*MultisizeImage{}
+	var imageErr error
+	if err := a.renditionsOf(attrs, RenditionTypeImage, func(cb *RenditionCallback) (stop bool) {
+		size, ok := sizes[cb.Attrs[kRenditionAttributeType_Dimension2]]
+		if !ok {
+			return false
+		}
+		if cb.Err != nil {
+			imageErr = cb.Err
+			return true
+		}
+		list = append(list, &MultisizeImage{
+			MultisizeImageSize: size,
+			FileName:           cb.Name,
+			Scale:              uint16(cb.Attrs[kRenditionAttributeType_Scale]),
+			Attrs:              cb.Attrs,
+			Image:              cb.Image,
+		})
+		return false
+	}); err != nil {
+		return nil, err
+	}
+	if imageErr != nil {
+		return nil, imageErr
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Width != b.Width {
+			return a.Width < b.Width
+		}
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		return a.Scale < b.Scale
+	})
+	return list, nil
+}
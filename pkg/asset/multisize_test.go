@@ -0,0 +1,101 @@
+package asset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"testing"
+)
+
+// sism: 'SISM' rendition of sizes
+func sism(sizes ...MultisizeImageSize) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("SISM")
+	binary.Write(b, binary.LittleEndian, []uint32{1, uint32(len(sizes))})
+	binary.Write(b, binary.LittleEndian, sizes)
+	return b.Bytes()
+}
+
+func TestMultisizeImageSet(t *testing.T) {
+	f, err := os.Open("../bom/test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, err := NewWithReadSeeker(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sets, err := a.MultisizeImageSets("AppIcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("sets: %d", len(sets))
+	}
+	want := []struct {
+		size  MultisizeImageSize
+		scale uint16
+	}{
+		{MultisizeImageSize{Width: 60, Height: 60, Index: 0}, 3},
+		{MultisizeImageSize{Width: 90, Height: 90, Index: 1}, 2},
+	}
+	for i, s := range sets {
+		if s.Tag.String() != "SISM" || s.Name != "AppIcon" || len(s.Sizes) != 1 || s.Sizes[0] != want[i].size {
+			t.Fatalf("set %d: %+v", i, s)
+		}
+		if idiomName(s.Attrs[kRenditionAttributeType_Idiom]) != "phone" {
+			t.Fatalf("set %d: attrs %v", i, s.Attrs)
+		}
+		images, err := a.ResolveMultisize(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(images) != 1 {
+			t.Fatalf("set %d: images %d", i, len(images))
+		}
+		img := images[0]
+		if img.MultisizeImageSize != want[i].size || img.Scale != want[i].scale || img.FileName != "icon-1.png" {
+			t.Fatalf("set %d: %+v", i, img)
+		}
+		// size in points at scale
+		if img.Image.Bounds() != image.Rect(0, 0, int(img.Width)*int(img.Scale), int(img.Height)*int(img.Scale)) {
+			t.Fatalf("set %d: bounds %v", i, img.Image.Bounds())
+		}
+	}
+
+	if _, err := a.MultisizeImageSets("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing: %v", err)
+	}
+}
+
+func TestDecodeMultisize(t *testing.T) {
+	sizes := []MultisizeImageSize{
+		{Width: 20, Height: 20, Index: 0, Idiom: 2},
+		{Width: 29, Height: 29, Index: 1, Idiom: 2},
+		{Width: 83, Height: 83, Index: 2, Idiom: 2},
+	}
+	s, err := decodeMultisize(bytes.NewReader(sism(sizes...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 1 || s.SizesCount != 3 || len(s.Sizes) != 3 {
+		t.Fatalf("%+v", s)
+	}
+	for i := range sizes {
+		if s.Sizes[i] != sizes[i] {
+			t.Fatalf("size %d: %+v", i, s.Sizes[i])
+		}
+	}
+
+	d := sism(sizes...)
+	for _, n := range []int{8, 12 + 12*2 + 4} {
+		if _, err := decodeMultisize(bytes.NewReader(d[:n])); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("truncated %d: %v", n, err)
+		}
+	}
+}
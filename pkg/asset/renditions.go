@@ -262,12 +262,21 @@ const (
 
 var kCoreThemeIdiomNames = [kCoreThemeIdiomMax]string{"", "phone", "pad", "tv", "car", "watch", "marketing"}
 
+// CUIThemeMultisizeImageSetRendition: header and first size of 'SISM' rendition,
+// the field names do not follow the layout and are kept for compatibility
+//
+// Deprecated: use MultisizeImageSet, which decodes every size
 type CUIThemeMultisizeImageSetRendition struct {
 	// uint32_t tag;					// 'SISM'
-	Tag    helper.String4
-	Idiom  kCoreThemeIdiom // ?
-	Scale  uint32          // ?
-	Width  uint32
+	Tag helper.String4
+	// Idiom: version of 'SISM', not an idiom
+	Idiom kCoreThemeIdiom
+	// Scale: count of sizes, not a scale
+	Scale uint32
+	// Width: width of first size
+	Width uint32
+	// Heigth: height of first size
 	Heigth uint32
-	Index  uint32
+	// Index: index of first size in low 16 bits, its idiom in high 16 bits
+	Index uint32
 }
@@ -0,0 +1,204 @@
+package asset
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VectorRendition: PDF or SVG source artwork of rendition
+type VectorRendition struct {
+	// file name in catalog
+	Name string
+	// "PDF" or "SVG"
+	Format string
+	// natural size in points, from csi header, or from MediaBox of PDF and width, height or viewBox of SVG
+	Width  float64
+	Height float64
+	// 1 for @1x, 2 for @2x, 3 for @3x
+	Scale float64
+	Attrs RenditionAttrs
+	Data  []byte
+}
+
+// isVector: rendition is PDF or SVG payload,
+// by pixel format, kRenditionLayoutType_Vector layout or vector flags of renditions without pixel format
+func isVector(format string, c *csiheader) bool {
+	switch format {
+	case "PDF", "SVG":
+		return true
+	case string([]byte{0, 0, 0, 0}):
+	default:
+		return false
+	}
+	switch c.Csimetadata.Layout {
+	case kRenditionLayoutType_Vector:
+		return true
+	case kRenditionLayoutType_Color, kRenditionLayoutType_MultisizeImage:
+		return false
+	}
+	return c.RenditionFlags.IsVectorBased() != 0 || c.RenditionFlags.PreservedVectorRepresentation() != 0
+}
+
+// readVector: payload of vector rendition with natural size and scale
+func (a *asset) readVector(format string, d io.Reader, c *csiheader, attrs RenditionAttrs) (*VectorRendition, error) {
+	data, err := a.readPayload(d)
+	if err != nil {
+		return nil, err
+	}
+	if format != "PDF" && format != "SVG" {
+		format = sniffVector(data)
+		if format == "" {
+			return nil, ErrUnsupportedPixelFormat
+		}
+	}
+
+	v := &VectorRendition{
+		Name:   c.Csimetadata.Name.String(),
+		Format: format,
+		Width:  float64(c.Width),
+		Height: float64(c.Height),
+		Scale:  float64(c.ScaleFactor) / 100,
+		Attrs:  attrs,
+		Data:   data,
+	}
+	if v.Scale == 0 {
+		v.Scale = float64(attrs[kRenditionAttributeType_Scale])
+	}
+	if v.Scale == 0 {
+		// single scale vectors have no scale attribute
+		v.Scale = 1
+	}
+	if v.Width == 0 || v.Height == 0 {
+		if format == "PDF" {
+			v.Width, v.Height = pdfSize(data)
+		} else {
+			v.Width, v.Height = svgSize(data)
+		}
+	}
+	return v, nil
+}
+
+// sniffVector: "PDF" or "SVG" by content, empty if unknown
+func sniffVector(d []byte) string {
+	if bytes.HasPrefix(d, []byte("%PDF-")) {
+		return "PDF"
+	}
+	head := d
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if bytes.Contains(head, []byte("<svg")) {
+		return "SVG"
+	}
+	return ""
+}
+
+var mediaBox = regexp.MustCompile(`/MediaBox\s*\[\s*(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s*\]`)
+
+// pdfSize: size of first MediaBox, 0 if not found, like compressed object streams
+func pdfSize(d []byte) (float64, float64) {
+	m := mediaBox.FindSubmatch(d)
+	if m == nil {
+		return 0, 0
+	}
+	v := [4]float64{}
+	for i := range v {
+		f, err := strconv.ParseFloat(string(m[i+1]), 64)
+		if err != nil {
+			return 0, 0
+		}
+		v[i] = f
+	}
+	return v[2] - v[0], v[3] - v[1]
+}
+
+// svgSize: width and height of root element, or size of viewBox if they are missing or relative
+func svgSize(d []byte) (float64, float64) {
+	dec := xml.NewDecoder(bytes.NewReader(d))
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return 0, 0
+		}
+		root, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		attr := map[string]string{}
+		for _, a := range root.Attr {
+			attr[a.Name.Local] = a.Value
+		}
+		w, h := svgLength(attr["width"]), svgLength(attr["height"])
+		if w > 0 && h > 0 {
+			return w, h
+		}
+		box := strings.FieldsFunc(attr["viewBox"], func(r rune) bool { return r == ' ' || r == ',' })
+		if len(box) != 4 {
+			return 0, 0
+		}
+		return svgLength(box[2]), svgLength(box[3])
+	}
+}
+
+// svgLength: length in user units, like "24" or "24px", 0 for relative lengths like "100%"
+func svgLength(s string) float64 {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "px")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// Vector: PDF or SVG of first vector rendition of name
+func (a *asset) Vector(name string) (*VectorRendition, error) {
+	attrs, err := a.facet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var v *VectorRendition
+	var decodeErr error
+	if err := a.renditionsOf(attrs, RenditionTypeVector, func(cb *RenditionCallback) (stop bool) {
+		if cb.Err != nil {
+			if decodeErr == nil {
+				decodeErr = cb.Err
+			}
+			return false
+		}
+		v = cb.Vector
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	if v != nil {
+		return v, nil
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return nil, &RenditionError{Name: name, Err: ErrNotFound}
+}
+
+// VectorWalker: every vector rendition with facet name, for exporting source artwork,
+// names can repeat for scales, idioms and appearances, renditions with errors are skipped
+func (a *asset) VectorWalker(loop func(name string, v *VectorRendition) (end bool)) error {
+	names, err := a.facetNames()
+	if err != nil {
+		return err
+	}
+	return a.renditionsOf(nil, RenditionTypeVector, func(cb *RenditionCallback) (stop bool) {
+		if cb.Err != nil {
+			return false
+		}
+		name, ok := names[cb.Attrs[kRenditionAttributeType_Identifier]]
+		if !ok {
+			return false
+		}
+		return loop(name, cb.Vector)
+	})
+}
@@ -0,0 +1,94 @@
+package asset
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestVector(t *testing.T) {
+	a := testCatalog(t)
+
+	tests := []struct {
+		name, file, format string
+		width, height      float64
+		scale              float64
+		data               string
+	}{
+		{"icon", "icon.pdf", "PDF", 24, 20, 2, testPDF},
+		{"svg", "icon.svg", "SVG", 16, 12, 1, testSVG},
+		{"logo", "logo.pdf", "PDF", 30.5, 40, 3, testPDF},
+	}
+	for _, tt := range tests {
+		v, err := a.Vector(tt.name)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if v.Name != tt.file || v.Format != tt.format || v.Width != tt.width || v.Height != tt.height || v.Scale != tt.scale {
+			t.Fatalf("%s: %+v", tt.name, v)
+		}
+		if !bytes.Equal(v.Data, []byte(tt.data)) {
+			t.Fatalf("%s: data %q", tt.name, v.Data)
+		}
+	}
+
+	var re *RenditionError
+	if _, err := a.Vector("broken.pdf"); !errors.As(err, &re) || re.Format != "PDF" || !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("broken: %v", err)
+	}
+	for _, name := range []string{"missing", "raw"} {
+		if _, err := a.Vector(name); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	names := []string{}
+	if err := a.VectorWalker(func(name string, v *VectorRendition) (end bool) {
+		names = append(names, name)
+		return false
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if len(names) != 3 || names[0] != "icon" || names[1] != "logo" || names[2] != "svg" {
+		t.Fatalf("walker: %v", names)
+	}
+}
+
+func TestVectorSize(t *testing.T) {
+	tests := []struct {
+		format, data  string
+		width, height float64
+	}{
+		{"PDF", testPDF, 30.5, 40},
+		{"PDF", "%PDF-1.7\n/MediaBox[ -10 -10 90 40 ]", 100, 50},
+		{"PDF", "%PDF-1.7\n/Type /Page", 0, 0},
+		{"SVG", testSVG, 16, 12},
+		{"SVG", `<svg width="24px" height="18" viewBox="0 0 48 36"/>`, 24, 18},
+		{"SVG", `<svg viewBox="0,0,10,5"/>`, 10, 5},
+		{"SVG", `<svg/>`, 0, 0},
+		{"SVG", `not xml`, 0, 0},
+	}
+	for _, tt := range tests {
+		var w, h float64
+		if tt.format == "PDF" {
+			w, h = pdfSize([]byte(tt.data))
+		} else {
+			w, h = svgSize([]byte(tt.data))
+		}
+		if w != tt.width || h != tt.height {
+			t.Fatalf("%q: %v %v", tt.data, w, h)
+		}
+	}
+
+	if f := sniffVector([]byte(testSVG)); f != "SVG" {
+		t.Fatalf("sniff svg: %q", f)
+	}
+	if f := sniffVector([]byte(testPDF)); f != "PDF" {
+		t.Fatalf("sniff pdf: %q", f)
+	}
+	if f := sniffVector([]byte("\x89PNG")); f != "" {
+		t.Fatalf("sniff png: %q", f)
+	}
+}
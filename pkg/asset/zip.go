@@ -0,0 +1,66 @@
+package asset
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+const catalogName = "Assets.car"
+
+// FindCatalog: Assets.car of main app in IPA, "Payload/<name>.app/Assets.car",
+// or for other archives the Assets.car with the shortest path
+func FindCatalog(z *zip.Reader) (*zip.File, error) {
+	list := []*zip.File{}
+	for _, f := range z.File {
+		if path.Base(f.Name) != catalogName || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		dir := path.Dir(f.Name)
+		if path.Dir(dir) == "Payload" && strings.HasSuffix(dir, ".app") {
+			return f, nil
+		}
+		list = append(list, f)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%s: %w", catalogName, ErrNotFound)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if len(list[i].Name) != len(list[j].Name) {
+			return len(list[i].Name) < len(list[j].Name)
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list[0], nil
+}
+
+// NewWithZip: asset of Assets.car in IPA or zip file, r and size are of the zip file, see FindCatalog
+// stored entry is read in place, deflated entry is decompressed into memory, no more than opts.MaxDecompressedBytes
+func NewWithZip(r io.ReaderAt, size int64, opts bom.Options) (*asset, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	f, err := FindCatalog(z)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithZipEntry(r, f, opts)
+}
+
+// NewWithZipEntry: asset of car file f in zip file r
+func NewWithZipEntry(r io.ReaderAt, f *zip.File, opts bom.Options) (*asset, error) {
+	b, err := bom.NewWithZipEntry(r, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Parse(); err != nil {
+		return nil, err
+	}
+	return New(b), nil
+}
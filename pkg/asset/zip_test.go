@@ -0,0 +1,119 @@
+package asset
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/iineva/bom/pkg/bom"
+)
+
+// buildZip: zip of files, content nil means a small text file, names ending with "/" are directories
+func buildZip(t *testing.T, method uint16, files map[string][]byte) []byte {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, d := range files {
+		if d == nil {
+			d = []byte(name)
+		}
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		if _, err := f.Write(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewWithZip(t *testing.T) {
+	car, err := ioutil.ReadFile("../bom/test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"Payload/Test.app/Info.plist":                        nil,
+		"Payload/Test.app/Frameworks/A.framework/Assets.car": nil,
+		"Payload/Test.app/Assets.car":                        car,
+	}
+
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		d := buildZip(t, method, files)
+		a, err := NewWithZip(bytes.NewReader(d), int64(len(d)), bom.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := a.Image("AppIcon")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds().Dx() != 180 {
+			t.Fatalf("AppIcon: %v", img.Bounds())
+		}
+
+		z, err := zip.NewReader(bytes.NewReader(d), int64(len(d)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := FindCatalog(z)
+		if err != nil || f.Name != "Payload/Test.app/Assets.car" {
+			t.Fatalf("FindCatalog: %v %v", f, err)
+		}
+		// stored entry is read in place, without copy
+		r, size, err := bom.OpenZipEntry(bytes.NewReader(d), f, 0)
+		if err != nil || size != int64(len(car)) {
+			t.Fatalf("OpenZipEntry: %v %v", size, err)
+		}
+		if _, inPlace := r.(*bytes.Reader); inPlace == (method == zip.Store) {
+			t.Fatalf("method %d: %T", method, r)
+		}
+	}
+
+	// deflated entry larger than limit
+	d := buildZip(t, zip.Deflate, files)
+	if _, err := NewWithZip(bytes.NewReader(d), int64(len(d)), bom.Options{MaxDecompressedBytes: 1024}); !errors.Is(err, bom.ErrLimitExceeded) {
+		t.Fatalf("limit: %v", err)
+	}
+}
+
+func TestFindCatalog(t *testing.T) {
+	cases := []struct {
+		files []string
+		want  string
+	}{
+		{[]string{"b/c/Assets.car", "a/Assets.car", "Payload/", "Payload/x.txt"}, "a/Assets.car"},
+		{[]string{"Payload/A.app/Frameworks/B.framework/Assets.car", "Payload/A.app/Assets.car"}, "Payload/A.app/Assets.car"},
+		{[]string{"Payload/A.app/Info.plist"}, ""},
+	}
+	for _, c := range cases {
+		files := map[string][]byte{}
+		for _, name := range c.files {
+			files[name] = nil
+		}
+		d := buildZip(t, zip.Store, files)
+		z, err := zip.NewReader(bytes.NewReader(d), int64(len(d)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := FindCatalog(z)
+		if c.want == "" {
+			if !errors.Is(err, ErrNotFound) {
+				t.Fatalf("%v: %v", c.files, err)
+			}
+			continue
+		}
+		if err != nil || f.Name != c.want {
+			t.Fatalf("%v: %v %v", c.files, f, err)
+		}
+	}
+}
@@ -42,6 +42,14 @@ type BlockTable struct {
 	BlockPointers []*Pointer
 }
 
+// follows block pointers of BlockTable
+type FreeList struct {
+	// uint32_t numberOfFreeListPointers;
+	NumberOfFreeListPointers uint32
+	// BOMPointer freelistPointers[];
+	FreeListPointers []Pointer
+}
+
 type Vars struct {
 	// uint32_t count;
 	Count uint32
@@ -70,10 +78,15 @@ type TreeEntry struct {
 	BlockSize uint32
 	// uint32_t pathCount;   // Total number of paths in all leaves combined
 	PathCount uint32
-	// uint8_t unknown3;
+	// uint8_t unknown3;    // 1 for trees with inline keys, like BITMAPKEYS
 	Unknown3 uint8
 }
 
+// InlineKeys: KeyIndex of entries is the key itself, a big endian uint32, instead of index of key block
+func (e *TreeEntry) InlineKeys() bool {
+	return e.Unknown3 == 1
+}
+
 type TreeIndex struct {
 	// uint32_t index0; /* for leaf: points to BOMPathInfo1, for branch points to BOMPaths */
 	ValueIndex uint32
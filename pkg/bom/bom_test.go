@@ -2,11 +2,16 @@ package bom
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
+
+	"github.com/iineva/bom/pkg/helper"
 )
 
 func openTestData() (io.ReadSeekCloser, error) {
@@ -73,3 +78,623 @@ func TestParser(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// build a BOMStore file from raw blocks, block index i+1 is blocks[i]
+func buildTestBom(blocks [][]byte, vars []Var) []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(make([]byte, 512))
+
+	pointers := []Pointer{{}}
+	for _, v := range blocks {
+		pointers = append(pointers, Pointer{Address: uint32(buf.Len()), Length: uint32(len(v))})
+		buf.Write(v)
+	}
+
+	indexOffset := buf.Len()
+	binary.Write(buf, binary.BigEndian, uint32(len(pointers)))
+	binary.Write(buf, binary.BigEndian, pointers)
+	indexLength := buf.Len() - indexOffset
+
+	varsOffset := buf.Len()
+	binary.Write(buf, binary.BigEndian, uint32(len(vars)))
+	for _, v := range vars {
+		binary.Write(buf, binary.BigEndian, v.Index)
+		binary.Write(buf, binary.BigEndian, uint8(len(v.Name)))
+		buf.WriteString(v.Name)
+	}
+	varsLength := buf.Len() - varsOffset
+
+	header := &Header{
+		Magic:          helper.NewString8(HeaderMagic),
+		Version:        1,
+		NumberOfBlocks: uint32(len(blocks)),
+		IndexOffset:    uint32(indexOffset),
+		IndexLength:    uint32(indexLength),
+		VarsOffset:     uint32(varsOffset),
+		VarsLength:     uint32(varsLength),
+	}
+	d := buf.Bytes()
+	hbuf := &bytes.Buffer{}
+	binary.Write(hbuf, binary.BigEndian, header)
+	copy(d, hbuf.Bytes())
+	return d
+}
+
+func testTreeEntry(index uint32, count uint32) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, &TreeEntry{
+		Tag:       helper.NewString4("tree"),
+		Version:   1,
+		Index:     index,
+		BlockSize: 4096,
+		PathCount: count,
+	})
+	return buf.Bytes()
+}
+
+func testTreePage(isLeaf bool, forward, backward uint32, list []TreeIndex) []byte {
+	buf := &bytes.Buffer{}
+	leaf := uint16(0)
+	if isLeaf {
+		leaf = 1
+	}
+	binary.Write(buf, binary.BigEndian, leaf)
+	binary.Write(buf, binary.BigEndian, uint16(len(list)))
+	binary.Write(buf, binary.BigEndian, forward)
+	binary.Write(buf, binary.BigEndian, backward)
+	binary.Write(buf, binary.BigEndian, list)
+	return buf.Bytes()
+}
+
+// tree with one branch page and three leaf pages, keys are "k0" ... "k8"
+// branch keys are the last key of each leaf page, like pages of Writer
+//
+// block 1: tree entry
+// block 2: branch page
+// block 3-5: leaf pages
+// block 6-23: key, value pairs
+func buildMultiPageTree(cycle bool) []byte {
+	const pages = 3
+	const perPage = 3
+	blocks := [][]byte{
+		testTreeEntry(2, pages*perPage),
+		nil,
+	}
+	kv := [][]byte{}
+	branch := []TreeIndex{}
+	for p := 0; p < pages; p++ {
+		forward := uint32(3 + p + 1)
+		backward := uint32(3 + p - 1)
+		if p == pages-1 {
+			forward = 0
+			if cycle {
+				forward = 3
+			}
+		}
+		if p == 0 {
+			backward = 0
+		}
+		list := []TreeIndex{}
+		for i := 0; i < perPage; i++ {
+			n := p*perPage + i
+			ki := uint32(6 + len(kv))
+			kv = append(kv, []byte(fmt.Sprintf("k%d", n)), []byte(fmt.Sprintf("v%d", n)))
+			list = append(list, TreeIndex{ValueIndex: ki + 1, KeyIndex: ki})
+		}
+		branch = append(branch, TreeIndex{ValueIndex: uint32(3 + p), KeyIndex: list[len(list)-1].KeyIndex})
+		blocks = append(blocks, testTreePage(true, forward, backward, list))
+	}
+	blocks[1] = testTreePage(false, 0, 0, branch)
+	blocks = append(blocks, kv...)
+	return buildTestBom(blocks, []Var{{Index: 1, Name: "TREE"}})
+}
+
+func TestReadTreeMultiPage(t *testing.T) {
+	b := New(bytes.NewReader(buildMultiPageTree(false)))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{}
+	values := []string{}
+	if err := b.ReadTree("TREE", func(k io.Reader, d io.Reader) error {
+		kb, err := ioutil.ReadAll(k)
+		if err != nil {
+			return err
+		}
+		db, err := ioutil.ReadAll(d)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, string(kb))
+		values = append(values, string(db))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tkeys := []string{"k0", "k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8"}
+	tvalues := []string{"v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7", "v8"}
+	if !reflect.DeepEqual(tkeys, keys) {
+		t.Fatalf("keys: %v", keys)
+	}
+	if !reflect.DeepEqual(tvalues, values) {
+		t.Fatalf("values: %v", values)
+	}
+}
+
+func TestReadTreeCycle(t *testing.T) {
+	b := New(bytes.NewReader(buildMultiPageTree(true)))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	err := b.ReadTree("TREE", func(k io.Reader, d io.Reader) error {
+		n++
+		return nil
+	})
+	if !errors.Is(err, ErrTreeCycle) {
+		t.Fatalf("want ErrTreeCycle, got: %v", err)
+	}
+	if n != 9 {
+		t.Fatalf("want 9 entries before cycle, got: %v", n)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	f, err := openTestData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b := New(f)
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	// APPEARANCEKEYS value is uint16 big endian
+	r, err := b.Lookup("APPEARANCEKEYS", []byte("UIAppearanceAny"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, []byte{0, 0}) {
+		t.Fatalf("APPEARANCEKEYS value: %v", d)
+	}
+
+	for _, k := range []string{"AppIcon", "test", "test2", "test3"} {
+		if _, err := b.Lookup("FACETKEYS", []byte(k), CompareBytes); err != nil {
+			t.Fatalf("FACETKEYS %v: %v", k, err)
+		}
+	}
+	if _, err := b.Lookup("FACETKEYS", []byte("test1"), nil); err != ErrKeyNotFound {
+		t.Fatalf("want ErrKeyNotFound, got: %v", err)
+	}
+
+	// RENDITIONS key: scale, idiom, subtype, dimension2, identifier, element, part
+	key := []byte{2, 0, 1, 0, 0, 7, 1, 0, 0xc1, 0x1a, 0x55, 0, 0xdc, 0}
+	if _, err := b.Lookup("RENDITIONS", key, nil); err != nil {
+		t.Fatal(err)
+	}
+	// RENDITIONS keys are in byte order, not in order of uint16 values, every key can be found
+	it, err := b.Iterator("RENDITIONS", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last []byte
+	for it.Next() {
+		if last != nil && CompareBytes(last, it.Key()) >= 0 {
+			t.Fatalf("RENDITIONS order: %x %x", last, it.Key())
+		}
+		last = append(last[:0], it.Key()...)
+		if _, err := b.Lookup("RENDITIONS", it.Key(), nil); err != nil {
+			t.Fatalf("RENDITIONS %x: %v", it.Key(), err)
+		}
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if _, err := b.Lookup("NOTFOUND", key, nil); err != ErrNameNotMatch {
+		t.Fatalf("want ErrNameNotMatch, got: %v", err)
+	}
+}
+
+func TestLookupMultiPage(t *testing.T) {
+	b := New(bytes.NewReader(buildMultiPageTree(false)))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 9; i++ {
+		r, err := b.Lookup("TREE", []byte(fmt.Sprintf("k%d", i)), nil)
+		if err != nil {
+			t.Fatalf("k%d: %v", i, err)
+		}
+		d, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != fmt.Sprintf("v%d", i) {
+			t.Fatalf("k%d: %s", i, d)
+		}
+	}
+	for _, k := range []string{"a", "k", "k10", "k9", "z"} {
+		if _, err := b.Lookup("TREE", []byte(k), nil); err != ErrKeyNotFound {
+			t.Fatalf("%v: want ErrKeyNotFound, got: %v", k, err)
+		}
+	}
+}
+
+func TestCompareUint16LE(t *testing.T) {
+	a := []byte{0x4f, 0x68}
+	b := []byte{0xa3, 0x41}
+	if CompareUint16LE(a, b) != 1 || CompareBytes(a, b) != -1 {
+		t.Fail()
+	}
+	if CompareUint16LE([]byte{1, 0, 2}, []byte{1, 0}) != 1 {
+		t.Fail()
+	}
+}
+
+// hide ReadAt of bytes.Reader
+type readSeeker struct {
+	io.ReadSeeker
+}
+
+func TestParallelRead(t *testing.T) {
+	d, err := ioutil.ReadFile("test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsers := []BomStore{
+		New(readSeeker{bytes.NewReader(d)}),
+		NewReaderAt(bytes.NewReader(d), int64(len(d))),
+	}
+	for _, b := range parsers {
+		if err := b.Parse(); err != nil {
+			t.Fatal(err)
+		}
+
+		wants := map[string][]byte{}
+		for _, name := range b.BlockNames() {
+			r, err := b.ReadBlock(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wants[name], err = ioutil.ReadAll(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		errs := make(chan error)
+		for i := 0; i < 16; i++ {
+			go func() {
+				for n := 0; n < 20; n++ {
+					for name, want := range wants {
+						r, err := b.ReadBlock(name)
+						if err != nil {
+							errs <- err
+							return
+						}
+						got, err := ioutil.ReadAll(r)
+						if err != nil {
+							errs <- err
+							return
+						}
+						if !bytes.Equal(want, got) {
+							errs <- fmt.Errorf("block %v not match", name)
+							return
+						}
+					}
+					if _, err := b.Lookup("FACETKEYS", []byte("test2"), nil); err != nil {
+						errs <- err
+						return
+					}
+				}
+				errs <- nil
+			}()
+		}
+		for i := 0; i < 16; i++ {
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	d := buildTestBom([][]byte{
+		testTreeEntry(2, 1),
+		testTreePage(true, 0, 0, []TreeIndex{{ValueIndex: 7, KeyIndex: 3}}),
+		[]byte("k"),
+	}, []Var{{Index: 1, Name: "TREE"}})
+
+	c := append([]byte{}, d...)
+	copy(c, "BOMStorX")
+	err := New(bytes.NewReader(c)).Parse()
+	e := &Error{}
+	if !errors.Is(err, ErrBadMagic) || !errors.As(err, &e) || e.Op != "read header" {
+		t.Fatalf("bad magic: %v", err)
+	}
+
+	if err := New(bytes.NewReader(d[:len(d)-3])).Parse(); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("truncated: %v", err)
+	}
+
+	b := New(bytes.NewReader(d))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	err = b.ReadTree("TREE", func(k io.Reader, d io.Reader) error {
+		return nil
+	})
+	if !errors.As(err, &e) || e.Var != "TREE" || e.Block != 7 || !errors.Is(err, ErrBlockNotFound) {
+		t.Fatalf("missing value: %v", err)
+	}
+
+	// errors of callback are returned as is
+	stop := errors.New("stop")
+	d = buildTestBom([][]byte{
+		testTreeEntry(2, 1),
+		testTreePage(true, 0, 0, []TreeIndex{{ValueIndex: 4, KeyIndex: 3}}),
+		[]byte("k"),
+		[]byte("v"),
+	}, []Var{{Index: 1, Name: "TREE"}})
+	b = New(bytes.NewReader(d))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ReadTree("TREE", func(k io.Reader, d io.Reader) error {
+		return stop
+	}); err != stop {
+		t.Fatalf("callback: %v", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	f, err := os.Open("test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := New(f)
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Pointer{{Address: 9328, Length: 29}, {Address: 11488, Length: 1968}, {Address: 183168, Length: 2072}}
+	if !reflect.DeepEqual(b.FreeList(), want) {
+		t.Fatalf("free list: %v", b.FreeList())
+	}
+
+	s := b.Stats()
+	if s.FileSize != 189359 || s.Blocks != 39 || s.FreeExtents != 3 || s.FreeBytes != 4069 || s.LargestFreeExtent != 2072 {
+		t.Fatalf("stats: %+v", s)
+	}
+	if s.Fragmentation <= 0 || s.Fragmentation >= 1 {
+		t.Fatalf("fragmentation: %v", s.Fragmentation)
+	}
+	if s.SlackBytes < s.FreeBytes || s.UsedBytes+s.SlackBytes > s.FileSize {
+		t.Fatalf("slack: %+v", s)
+	}
+
+	// no free list and no free space
+	d := buildTestBom([][]byte{[]byte("a")}, []Var{{Index: 1, Name: "A"}})
+	b = New(bytes.NewReader(d))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.Stats(); len(b.FreeList()) != 0 || s.FreeBytes != 0 || s.Fragmentation != 0 || s.SlackBytes != 0 {
+		t.Fatalf("stats: %+v", s)
+	}
+}
+
+func TestInlineKeys(t *testing.T) {
+	f, err := os.Open("test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := New(f)
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := b.TreeEntry("BITMAPKEYS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !entry.InlineKeys() || entry.BlockSize != 1024 {
+		t.Fatalf("entry: %+v", entry)
+	}
+	keys := [][]byte{}
+	if err := b.ReadTree("BITMAPKEYS", func(k io.Reader, d io.Reader) error {
+		key, err := ioutil.ReadAll(k)
+		keys = append(keys, key)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{inlineKey(0x1AC1), inlineKey(0x41A3), inlineKey(0x684F), inlineKey(0xF4B0)}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("keys: %v", keys)
+	}
+	if _, err := b.Lookup("BITMAPKEYS", inlineKey(0x684F), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// writer, several pages
+	w := NewWriter()
+	w.BlockSize = treePageHeaderSize + 2*treeIndexSize
+	items := []TreeItem{}
+	for i := 0; i < 7; i++ {
+		items = append(items, TreeItem{Key: inlineKey(uint32(i * 1000)), Value: []byte{byte(i)}})
+	}
+	if _, err := w.WriteInlineTree("TREE", items); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteInlineTree("BAD", []TreeItem{{Key: []byte("k")}}); err != ErrInlineKeySize {
+		t.Fatalf("bad key: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b = NewReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if r := b.Verify(); !r.OK() {
+		t.Fatalf("verify: %v", r.Problems)
+	}
+	r, err := b.Lookup("TREE", inlineKey(5000), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := ioutil.ReadAll(r); !bytes.Equal(v, []byte{5}) {
+		t.Fatalf("value: %v", v)
+	}
+	it, err := b.Iterator("TREE", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for it.Next() {
+		if !bytes.Equal(it.Key(), items[n].Key) {
+			t.Fatalf("iterator key %d: %v", n, it.Key())
+		}
+		n++
+	}
+	if it.Err() != nil || n != len(items) {
+		t.Fatalf("iterator: %v %v", n, it.Err())
+	}
+}
+
+func openBench(b *testing.B) (BomStore, []byte) {
+	d, err := ioutil.ReadFile("test_data/Assets.car")
+	if err != nil {
+		b.Fatal(err)
+	}
+	p := NewReaderAt(bytes.NewReader(d), int64(len(d)))
+	if err := p.Parse(); err != nil {
+		b.Fatal(err)
+	}
+	return p, d
+}
+
+func BenchmarkParse(b *testing.B) {
+	_, d := openBench(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := NewReaderAt(bytes.NewReader(d), int64(len(d)))
+		if err := p.Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadTree(b *testing.B) {
+	p, _ := openBench(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, name := range []string{"RENDITIONS", "FACETKEYS", "APPEARANCEKEYS", "BITMAPKEYS"} {
+			if err := p.ReadTree(name, func(k io.Reader, d io.Reader) error {
+				_, err := ioutil.ReadAll(k)
+				return err
+			}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	p, _ := openBench(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Lookup("FACETKEYS", []byte("AppIcon"), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestDecode: hand written decoders match binary.Read
+func TestDecode(t *testing.T) {
+	d, err := ioutil.ReadFile("test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Header{}
+	if err := binary.Read(bytes.NewReader(d), binary.BigEndian, h); err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeHeader(d[:headerSize]); !reflect.DeepEqual(got, h) {
+		t.Fatalf("header: %+v, want %+v", got, h)
+	}
+
+	b := NewReaderAt(bytes.NewReader(d), int64(len(d))).(*bom)
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	pages := 0
+	for _, v := range b.Vars() {
+		name := v.Name
+		entry, err := b.TreeEntry(name)
+		if err != nil {
+			continue
+		}
+		want := &TreeEntry{}
+		r, _ := b.blockReader(v.Index)
+		if err := binary.Read(r, binary.BigEndian, want); err != nil || !reflect.DeepEqual(entry, want) {
+			t.Fatalf("%s: %+v, want %+v", name, entry, want)
+		}
+
+		page, err := b.readPage(entry.Index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _ = b.blockReader(entry.Index)
+		wantPage := &Tree{}
+		binary.Read(r, binary.BigEndian, &wantPage.IsLeaf)
+		binary.Read(r, binary.BigEndian, &wantPage.Count)
+		binary.Read(r, binary.BigEndian, &wantPage.Forward)
+		binary.Read(r, binary.BigEndian, &wantPage.Backward)
+		wantPage.List = make([]TreeIndex, wantPage.Count)
+		if err := binary.Read(r, binary.BigEndian, wantPage.List); err != nil || !reflect.DeepEqual(page, wantPage) {
+			t.Fatalf("%s page: %+v, want %+v", name, page, wantPage)
+		}
+		pages++
+	}
+	if pages != 4 {
+		t.Fatalf("pages: %d", pages)
+	}
+
+	if _, err := decodeTreeEntry([]byte("tree")); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("short entry: %v", err)
+	}
+}
+
+// BenchmarkDecodeHeader: binary.Read compared with hand written decoder
+func BenchmarkDecodeHeader(b *testing.B) {
+	_, d := openBench(b)
+	b.Run("binary.Read", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h := &Header{}
+			if err := binary.Read(bytes.NewReader(d), binary.BigEndian, h); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cursor", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decodeHeader(d[:headerSize])
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package bom
+
+import (
+	"io"
+)
+
+// crc table of POSIX cksum, polynomial 0x04C11DB7, most significant bit first
+var cksumTable = func() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return
+}()
+
+// Cksum: checksum of BSD cksum command, stored in installer BOM files
+// returns checksum and number of bytes read
+func Cksum(r io.Reader) (uint32, int64, error) {
+	crc := uint32(0)
+	n := int64(0)
+	buf := make([]byte, 32*1024)
+	for {
+		i, err := r.Read(buf)
+		for _, c := range buf[:i] {
+			crc = crc<<8 ^ cksumTable[byte(crc>>24)^c]
+		}
+		n += int64(i)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, n, err
+		}
+	}
+	// length of data, least significant byte first
+	for l := n; l > 0; l >>= 8 {
+		crc = crc<<8 ^ cksumTable[byte(crc>>24)^byte(l)]
+	}
+	return ^crc, n, nil
+}
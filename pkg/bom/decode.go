@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 
 	"github.com/iineva/bom/pkg/helper"
 	"github.com/iineva/bom/pkg/reader"
@@ -26,81 +25,176 @@ type BomParser interface {
 	ReadTree(name string, entry func(k io.Reader, d io.Reader) error) error
 }
 
+// BomStore: BomParser with access to the structure of file, lookups and checks,
+// parsers of this package implement it, implementations of BomParser are not required to
+type BomStore interface {
+	BomParser
+
+	// header of file
+	Header() Header
+
+	// block table, index 0 is the null entry
+	Blocks() []Pointer
+
+	// all vars in file order
+	Vars() []Var
+
+	// read block of block table index
+	ReadBlockIndex(index uint32) (io.Reader, error)
+
+	// tree entry of named tree block
+	TreeEntry(name string) (*TreeEntry, error)
+
+	// read tree page of block table index, with all entries
+	ReadTreePage(index uint32) (*Tree, error)
+
+	// find value of key in named tree, cmp nil means CompareBytes
+	Lookup(name string, key []byte, cmp KeyCompare) (io.Reader, error)
+
+	// create cursor of named tree, cmp nil means CompareBytes
+	Iterator(name string, cmp KeyCompare) (*TreeIterator, error)
+
+	// decode 'Paths' tree of installer BOM
+	Paths() ([]Path, error)
+
+	// check structure of file, returns all problems found
+	Verify() *Report
+
+	// free list after block table
+	FreeList() []Pointer
+
+	// block allocation statistics
+	Stats() *Stats
+
+	// limits of this parser
+	Options() Options
+}
+
 type bom struct {
-	r io.ReadSeeker
+	r    io.ReaderAt
+	size int64
+	err  error // error of New
+	opts Options
 
 	header     *Header
 	blockTable *BlockTable
+	freeList   *FreeList
 	vars       []Var
 }
 
-var _ BomParser = (*bom)(nil)
+var _ BomStore = (*bom)(nil)
 
 var (
 	// ErrBlockLengthZero = errors.New("block length is zero")
 	ErrBlockNotFound = errors.New("block not found")
 	ErrNameNotMatch  = errors.New("name not match")
+	ErrTreeCycle     = errors.New("tree page cycle")
+	ErrNotTree       = errors.New("not a tree")
+	// First entry of block table must always be a null entry
+	ErrFirstBlockNotNull = errors.New("first entry not be a null entry")
 )
 
-func New(r io.ReadSeeker) BomParser {
-	return &bom{r: r}
+// New: parser of io.ReadSeeker, reads are locked if r is not an io.ReaderAt
+func New(r io.ReadSeeker) BomStore {
+	size, err := r.Seek(0, io.SeekEnd)
+	return &bom{r: reader.NewReaderAt(r), size: size, err: err, opts: DefaultOptions}
 }
 
-func (b *bom) Parse() error {
+// NewReaderAt: parser of io.ReaderAt with size, safe for parallel use after Parse
+func NewReaderAt(r io.ReaderAt, size int64) BomStore {
+	return NewWithOptions(r, size, DefaultOptions)
+}
 
-	f := b.r
+// NewWithOptions: parser of io.ReaderAt with size and limits
+func NewWithOptions(r io.ReaderAt, size int64, opts Options) BomStore {
+	return &bom{r: r, size: size, opts: opts.withDefaults()}
+}
 
-	header := &Header{}
-	err := binary.Read(f, binary.BigEndian, header)
+func (b *bom) Options() Options {
+	return b.opts
+}
+
+func (b *bom) Parse() error {
+	if b.err != nil {
+		return b.err
+	}
+
+	d, err := b.readAt(0, headerSize)
 	if err != nil {
-		log.Printf("read header error: %v", err)
-		return err
+		return &Error{Op: "read header", Err: err}
 	}
+	header := decodeHeader(d)
 	if HeaderMagic != header.Magic.String() {
-		err := fmt.Errorf("header magic not match header.Magic[8] = '%s'", header.Magic.String())
-		log.Print(err)
-		return err
+		return &Error{Op: "read header", Err: fmt.Errorf("%w: '%s'", ErrBadMagic, header.Magic.String())}
 	}
 	b.header = header
 
 	// blockTable
-	f.Seek(int64(b.header.IndexOffset), 0)
+	offset := int64(header.IndexOffset)
+	d, err = b.readAt(offset, 4)
+	if err != nil {
+		return &Error{Op: "read block table", Offset: offset, Err: err}
+	}
 	blockTable := &BlockTable{}
 	// read table block length
-	binary.Read(f, binary.BigEndian, &blockTable.NumberOfBlockTablePointers)
-	// read table block pointers
-	blockTable.BlockPointers = make([]*Pointer, blockTable.NumberOfBlockTablePointers)
-	for i := 0; i < int(blockTable.NumberOfBlockTablePointers); i++ {
-		p := &Pointer{}
-		if err := binary.Read(f, binary.BigEndian, p); err != nil {
-			return err
-		}
-		// First entry must always be a null entry
-		if i == 0 && (p.Address != 0 || p.Length != 0) {
-			return errors.New("first entry not be a null entry")
-		}
-		blockTable.BlockPointers[i] = p
+	blockTable.NumberOfBlockTablePointers = binary.BigEndian.Uint32(d)
+	if blockTable.NumberOfBlockTablePointers > b.opts.MaxBlocks {
+		return &Error{Op: "read block table", Offset: offset, Err: &LimitError{Limit: "MaxBlocks", Value: int64(blockTable.NumberOfBlockTablePointers), Max: int64(b.opts.MaxBlocks)}}
+	}
+	// read table block pointers, every pointer is 8 bytes, must be in file
+	d, err = b.readAt(offset+4, int64(blockTable.NumberOfBlockTablePointers)*8)
+	if err != nil {
+		return &Error{Op: "read block table", Offset: offset, Err: err}
+	}
+	pointers := decodePointers(d)
+	blockTable.BlockPointers = make([]*Pointer, len(pointers))
+	for i := range pointers {
+		blockTable.BlockPointers[i] = &pointers[i]
+	}
+	// First entry must always be a null entry
+	if len(pointers) > 0 && (pointers[0].Address != 0 || pointers[0].Length != 0) {
+		return &Error{Op: "read block table", Offset: offset + 4, Err: ErrFirstBlockNotNull}
 	}
 	b.blockTable = blockTable
 
+	// free list, older writers may leave it out of index
+	freeList, err := b.readFreeList(offset+4+int64(blockTable.NumberOfBlockTablePointers)*8, offset+int64(header.IndexLength))
+	if err != nil {
+		return err
+	}
+	b.freeList = freeList
+
 	// read vars
-	f.Seek(int64(header.VarsOffset), 0)
-	vars := &Vars{}
-	binary.Read(f, binary.BigEndian, &vars.Count)
+	offset = int64(header.VarsOffset)
+	d, err = b.readAt(offset, 4)
+	if err != nil {
+		return &Error{Op: "read vars", Offset: offset, Err: err}
+	}
+	vars := &Vars{Count: binary.BigEndian.Uint32(d)}
+	if vars.Count > b.opts.MaxVars {
+		return &Error{Op: "read vars", Offset: offset, Err: &LimitError{Limit: "MaxVars", Value: int64(vars.Count), Max: int64(b.opts.MaxVars)}}
+	}
+	// every var is 5 to 5+255 bytes, read all of them at once, up to the end of file
+	n := int64(vars.Count) * (5 + 255)
+	if rest := b.size - offset - 4; n > rest {
+		n = rest
+	}
+	if int64(vars.Count)*5 > n {
+		return &Error{Op: "read vars", Offset: offset, Err: io.ErrUnexpectedEOF}
+	}
+	d, err = b.readAt(offset+4, n)
+	if err != nil {
+		return &Error{Op: "read vars", Offset: offset, Err: err}
+	}
+	c := helper.NewCursor(d, binary.BigEndian)
 	vars.List = make([]Var, vars.Count)
-	for i := 0; i < int(vars.Count); i++ {
-		v := Var{}
-		binary.Read(f, binary.BigEndian, &v.Index)
-		binary.Read(f, binary.BigEndian, &v.Length)
-
-		// parse name
-		name, err := helper.ReadString(f, int(v.Length))
-		if err != nil {
-			log.Printf("read var name error: %v", err)
-			return err
+	for i := range vars.List {
+		pos := offset + 4 + int64(c.Offset())
+		v := Var{Index: c.Uint32(), Length: c.Uint8()}
+		v.Name = string(c.Bytes(int(v.Length)))
+		if err := c.Err(); err != nil {
+			return &Error{Op: "read var", Block: v.Index, Offset: pos, Err: err}
 		}
-		v.Name = name
-
 		vars.List[i] = v
 	}
 	b.vars = vars.List
@@ -108,6 +202,44 @@ func (b *bom) Parse() error {
 	return nil
 }
 
+// readFreeList: read free list at offset, end is the end of index
+func (b *bom) readFreeList(offset, end int64) (*FreeList, error) {
+	freeList := &FreeList{}
+	if offset+4 > end {
+		return freeList, nil
+	}
+	d, err := b.readAt(offset, 4)
+	if err != nil {
+		return nil, &Error{Op: "read free list", Offset: offset, Err: err}
+	}
+	freeList.NumberOfFreeListPointers = binary.BigEndian.Uint32(d)
+	if freeList.NumberOfFreeListPointers > b.opts.MaxBlocks {
+		return nil, &Error{Op: "read free list", Offset: offset, Err: &LimitError{Limit: "MaxBlocks", Value: int64(freeList.NumberOfFreeListPointers), Max: int64(b.opts.MaxBlocks)}}
+	}
+	d, err = b.readAt(offset+4, int64(freeList.NumberOfFreeListPointers)*8)
+	if err != nil {
+		return nil, &Error{Op: "read free list", Offset: offset, Err: err}
+	}
+	freeList.FreeListPointers = decodePointers(d)
+	return freeList, nil
+}
+
+// readAt: n bytes at offset, io.ErrUnexpectedEOF if they are not all in file
+func (b *bom) readAt(offset, n int64) ([]byte, error) {
+	if offset < 0 || offset+n > b.size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	d := make([]byte, n)
+	m, err := b.r.ReadAt(d, offset)
+	if m == len(d) {
+		return d, nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, err
+}
+
 // Block: get block with name
 func (b *bom) ReadBlock(name string) (io.Reader, error) {
 	for _, v := range b.vars {
@@ -133,89 +265,259 @@ func (b *bom) BlockNames() []string {
 	return names
 }
 
+func (b *bom) Header() Header {
+	return *b.header
+}
+
+func (b *bom) Blocks() []Pointer {
+	list := make([]Pointer, len(b.blockTable.BlockPointers))
+	for i, p := range b.blockTable.BlockPointers {
+		list[i] = *p
+	}
+	return list
+}
+
+func (b *bom) Vars() []Var {
+	return append([]Var{}, b.vars...)
+}
+
+func (b *bom) ReadBlockIndex(index uint32) (io.Reader, error) {
+	return b.blockReader(index)
+}
+
+// TreeEntry: tree entry of named block, ErrNotTree if the block is not a tree
+func (b *bom) TreeEntry(name string) (*TreeEntry, error) {
+	for _, v := range b.vars {
+		if v.Name == name && v.Index < uint32(len(b.blockTable.BlockPointers)) && b.blockTable.BlockPointers[v.Index].Length < treeEntrySize {
+			return nil, &Error{Op: "read tree entry", Var: name, Block: v.Index, Err: ErrNotTree}
+		}
+	}
+	entry, err := b.treeEntry(name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Tag.String() != "tree" {
+		return nil, &Error{Op: "read tree entry", Var: name, Err: ErrNotTree}
+	}
+	return entry, nil
+}
+
+func (b *bom) ReadTreePage(index uint32) (*Tree, error) {
+	return b.readPage(index)
+}
+
 func (b *bom) blockReader(index uint32) (io.Reader, error) {
 	if index >= uint32(len(b.blockTable.BlockPointers)) {
-		return nil, ErrBlockNotFound
+		return nil, &Error{Op: "read block", Block: index, Err: ErrBlockNotFound}
 	}
 	p := b.blockTable.BlockPointers[index]
+	if int64(p.Address)+int64(p.Length) > b.size {
+		return nil, &Error{Op: "read block", Block: index, Offset: int64(p.Address), Err: io.ErrUnexpectedEOF}
+	}
 	return reader.New(b.r, int64(p.Address), int64(p.Length)), nil
 }
 
-// Block: get tree block with name
+// blockData: all bytes of block
+func (b *bom) blockData(index uint32) ([]byte, error) {
+	if index >= uint32(len(b.blockTable.BlockPointers)) {
+		return nil, &Error{Op: "read block", Block: index, Err: ErrBlockNotFound}
+	}
+	return b.blockSlice(index, 0, int64(b.blockTable.BlockPointers[index].Length))
+}
+
+// blockSlice: n bytes of block from offset, io.ErrUnexpectedEOF if they are not all in block
+func (b *bom) blockSlice(index uint32, offset, n int64) ([]byte, error) {
+	if index >= uint32(len(b.blockTable.BlockPointers)) {
+		return nil, &Error{Op: "read block", Block: index, Err: ErrBlockNotFound}
+	}
+	p := b.blockTable.BlockPointers[index]
+	if offset+n > int64(p.Length) {
+		return nil, &Error{Op: "read block", Block: index, Offset: int64(p.Address), Err: io.ErrUnexpectedEOF}
+	}
+	d, err := b.readAt(int64(p.Address)+offset, n)
+	if err != nil {
+		return nil, &Error{Op: "read block", Block: index, Offset: int64(p.Address), Err: err}
+	}
+	return d, nil
+}
+
+// ReadTree: call loop with key and value of every entry of named tree, in key order
 func (b *bom) ReadTree(name string, loop func(k io.Reader, d io.Reader) error) error {
-	for _, v := range b.vars {
+	entry, err := b.treeEntry(name)
+	if err != nil {
+		return err
+	}
 
-		if v.Name != name {
-			continue
-		}
+	// pages already visited, protect against Forward/child pointer cycles
+	visited := map[uint32]bool{}
+
+	index := entry.Index
+	tree, err := b.readPage(index)
+	if err != nil {
+		return withVar(err, name)
+	}
+	visited[index] = true
 
-		entryBuf, err := b.blockReader(v.Index)
+	// go down to the leftmost leaf
+	for depth := 1; tree.IsLeaf == 0; depth++ {
+		if depth >= b.opts.MaxTreeDepth {
+			return &Error{Op: "read tree page", Var: name, Block: index, Err: &LimitError{Limit: "MaxTreeDepth", Value: int64(depth + 1), Max: int64(b.opts.MaxTreeDepth)}}
+		}
+		if len(tree.List) == 0 {
+			return withVar(b.blockError("read tree page", index, io.ErrUnexpectedEOF), name)
+		}
+		index = tree.List[0].ValueIndex
+		if visited[index] {
+			return withVar(b.blockError("read tree page", index, ErrTreeCycle), name)
+		}
+		visited[index] = true
+		tree, err = b.readPage(index)
 		if err != nil {
-			return err
+			return withVar(err, name)
 		}
-		entry := TreeEntry{}
-		if err := binary.Read(entryBuf, binary.BigEndian, &entry); err != nil {
+	}
+
+	// walk every leaf page through Forward pointers
+	for {
+		if err := b.readLeaf(name, entry.InlineKeys(), tree, loop); err != nil {
 			return err
 		}
+		if tree.Forward == 0 {
+			return nil
+		}
+		index = tree.Forward
+		if visited[index] {
+			return withVar(b.blockError("read tree page", index, ErrTreeCycle), name)
+		}
+		visited[index] = true
+		tree, err = b.readPage(index)
+		if err != nil {
+			return withVar(err, name)
+		}
+	}
+}
 
-		tree, buf, err := b.readTree(entry.Index)
+// read all entries of one leaf page, errors of loop are returned as is
+func (b *bom) readLeaf(name string, inline bool, tree *Tree, loop func(k io.Reader, d io.Reader) error) error {
+	for _, pi := range tree.List {
+		// get key and data
+		kbuf, err := b.keyReader(pi, inline)
 		if err != nil {
-			return err
+			return withVar(err, name)
 		}
-		for tree.IsLeaf == 0 {
-			pi := TreeIndex{}
-			if err := binary.Read(buf, binary.BigEndian, &pi); err != nil {
-				return err
-			}
-			tree, buf, err = b.readTree(pi.ValueIndex)
-			if err != nil {
-				return err
-			}
+		vbuf, err := b.blockReader(pi.ValueIndex)
+		if err != nil {
+			return withVar(err, name)
 		}
-
-		tree.List = make([]TreeIndex, tree.Count)
-		for i := uint16(0); i < tree.Count; i++ {
-			pi := TreeIndex{}
-			binary.Read(buf, binary.BigEndian, &pi)
-			tree.List[i] = pi
-
-			// get key and data
-			kbuf, err := b.blockReader(pi.KeyIndex)
-			if err != nil {
-				// in case of BITMAPKEYS, i don't know why not found, temporary handle
-				if err == ErrBlockNotFound {
-					p := make([]byte, 4)
-					binary.BigEndian.PutUint32(p, pi.KeyIndex)
-					kbuf = bytes.NewBuffer(p)
-				} else {
-					return err
-				}
-			}
-			vbuf, err := b.blockReader(pi.ValueIndex)
-			if err != nil {
-				// return err
-			}
-			// loop callback entry
-			if err := loop(kbuf, vbuf); err != nil {
-				return err
-			}
+		// loop callback entry
+		if err := loop(kbuf, vbuf); err != nil {
+			return err
 		}
-		return nil
 	}
+	return nil
+}
 
-	return ErrNameNotMatch
+func (b *bom) readTreeEntry(index uint32) (*TreeEntry, error) {
+	d, err := b.blockSlice(index, 0, treeEntrySize)
+	if err != nil {
+		return nil, b.truncatedError("read tree entry", index, err)
+	}
+	entry, err := decodeTreeEntry(d)
+	if err != nil {
+		return nil, b.blockError("read tree entry", index, err)
+	}
+	return entry, nil
 }
 
-func (b *bom) readTree(index uint32) (*Tree, io.Reader, error) {
-	buf, err := b.blockReader(index)
+// keyReader: key of entry, inline keys are KeyIndex as big endian uint32
+func (b *bom) keyReader(pi TreeIndex, inline bool) (io.Reader, error) {
+	if inline {
+		return bytes.NewReader(inlineKey(pi.KeyIndex)), nil
+	}
+	return b.blockReader(pi.KeyIndex)
+}
+
+func inlineKey(k uint32) []byte {
+	p := make([]byte, 4)
+	binary.BigEndian.PutUint32(p, k)
+	return p
+}
+
+// readPage: read tree page header and all entries, without the unused rest of page
+func (b *bom) readPage(index uint32) (*Tree, error) {
+	d, err := b.blockSlice(index, 0, treePageHeaderSize)
+	if err != nil {
+		return nil, b.truncatedError("read tree page", index, err)
+	}
+	tree := decodeTreeHeader(d)
+	d, err = b.blockSlice(index, treePageHeaderSize, int64(tree.Count)*treeIndexSize)
 	if err != nil {
-		return nil, nil, err
+		return nil, b.truncatedError("read tree page", index, err)
+	}
+	tree.List = decodeTreeIndexes(d)
+	return tree, nil
+}
+
+// truncatedError: block shorter than the bytes op slices for decoding is reported as error of op,
+// fields are decoded from the slice by helper.Cursor, so a short block is found before decoding
+func (b *bom) truncatedError(op string, index uint32, err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return b.blockError(op, index, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+func decodeHeader(d []byte) *Header {
+	c := helper.NewCursor(d, binary.BigEndian)
+	h := &Header{}
+	c.Fill(h.Magic[:])
+	h.Version = c.Uint32()
+	h.NumberOfBlocks = c.Uint32()
+	h.IndexOffset = c.Uint32()
+	h.IndexLength = c.Uint32()
+	h.VarsOffset = c.Uint32()
+	h.VarsLength = c.Uint32()
+	return h
+}
+
+func decodePointers(d []byte) []Pointer {
+	c := helper.NewCursor(d, binary.BigEndian)
+	list := make([]Pointer, len(d)/8)
+	for i := range list {
+		list[i] = Pointer{Address: c.Uint32(), Length: c.Uint32()}
 	}
+	return list
+}
+
+func decodeTreeEntry(d []byte) (*TreeEntry, error) {
+	c := helper.NewCursor(d, binary.BigEndian)
+	e := &TreeEntry{}
+	c.Fill(e.Tag[:])
+	e.Version = c.Uint32()
+	e.Index = c.Uint32()
+	e.BlockSize = c.Uint32()
+	e.PathCount = c.Uint32()
+	e.Unknown3 = c.Uint8()
+	return e, c.Err()
+}
 
-	tree := &Tree{}
-	binary.Read(buf, binary.BigEndian, &tree.IsLeaf)
-	binary.Read(buf, binary.BigEndian, &tree.Count)
-	binary.Read(buf, binary.BigEndian, &tree.Forward)
-	binary.Read(buf, binary.BigEndian, &tree.Backward)
-	return tree, buf, nil
+// decodeTreeHeader: tree page header, d is treePageHeaderSize bytes
+func decodeTreeHeader(d []byte) *Tree {
+	c := helper.NewCursor(d, binary.BigEndian)
+	return &Tree{
+		IsLeaf:   c.Uint16(),
+		Count:    c.Uint16(),
+		Forward:  c.Uint32(),
+		Backward: c.Uint32(),
+	}
+}
+
+// decodeTreeIndexes: entries of tree page, every entry is treeIndexSize bytes
+func decodeTreeIndexes(d []byte) []TreeIndex {
+	c := helper.NewCursor(d, binary.BigEndian)
+	list := make([]TreeIndex, len(d)/treeIndexSize)
+	for i := range list {
+		list[i] = TreeIndex{ValueIndex: c.Uint32(), KeyIndex: c.Uint32()}
+	}
+	return list
 }
@@ -0,0 +1,324 @@
+package bom
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// DiffReport: difference of two files, compared by var name
+type DiffReport struct {
+	// vars only in b
+	VarsAdded []string
+	// vars only in a
+	VarsRemoved []string
+	// named blocks with different bytes, trees are in Trees
+	Blocks []BlockDiff
+	// trees with different keys or values
+	Trees []TreeDiff
+}
+
+// BlockDiff: named block with different bytes
+type BlockDiff struct {
+	Var       string
+	OldLength int64
+	NewLength int64
+}
+
+var (
+	ErrUnsortedTree = errors.New("tree keys not sorted")
+)
+
+// TreeDiff: keys of named tree, sorted by bytes
+type TreeDiff struct {
+	Var string
+	// keys only in b
+	Added [][]byte
+	// keys only in a
+	Removed [][]byte
+	// keys in both, with different values
+	Changed [][]byte
+}
+
+// Equal: no difference found
+func (d *DiffReport) Equal() bool {
+	return len(d.VarsAdded) == 0 && len(d.VarsRemoved) == 0 && len(d.Blocks) == 0 && len(d.Trees) == 0
+}
+
+// Diff: compare vars, named blocks and trees of a and b, Parse must be called first
+// trees are walked side by side with DiffTree, memory grows with number of differences only
+func Diff(a, b BomStore) (*DiffReport, error) {
+	d := &DiffReport{}
+	inA := map[string]bool{}
+	for _, name := range a.BlockNames() {
+		inA[name] = true
+	}
+	inB := map[string]bool{}
+	for _, name := range b.BlockNames() {
+		inB[name] = true
+		if !inA[name] {
+			d.VarsAdded = append(d.VarsAdded, name)
+		}
+	}
+
+	for _, name := range a.BlockNames() {
+		if !inB[name] {
+			d.VarsRemoved = append(d.VarsRemoved, name)
+			continue
+		}
+
+		ta, err := isTree(a, name)
+		if err != nil {
+			return nil, err
+		}
+		tb, err := isTree(b, name)
+		if err != nil {
+			return nil, err
+		}
+		if ta && tb {
+			t, err := diffTree(a, b, name)
+			if err != nil {
+				return nil, err
+			}
+			if len(t.Added) > 0 || len(t.Removed) > 0 || len(t.Changed) > 0 {
+				d.Trees = append(d.Trees, *t)
+			}
+			continue
+		}
+
+		da, err := readNamed(a, name)
+		if err != nil {
+			return nil, err
+		}
+		db, err := readNamed(b, name)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(da, db) {
+			d.Blocks = append(d.Blocks, BlockDiff{Var: name, OldLength: int64(len(da)), NewLength: int64(len(db))})
+		}
+	}
+	return d, nil
+}
+
+func isTree(b BomStore, name string) (bool, error) {
+	_, err := b.TreeEntry(name)
+	if errors.Is(err, ErrNotTree) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func readNamed(b BomStore, name string) ([]byte, error) {
+	r, err := b.ReadBlock(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// TreeChange: kind of difference of a tree key, see DiffTree
+type TreeChange int
+
+const (
+	TreeKeyAdded   = TreeChange(1) // key only in b
+	TreeKeyRemoved = TreeChange(2) // key only in a
+	TreeKeyChanged = TreeChange(3) // key in both, with different values
+)
+
+// DiffTree: walk named tree of a and b side by side and call loop for every difference, in key order
+// pages of the two files may be split differently, only keys and values are compared,
+// values of installer 'Paths' are compared by id and the BOMPathInfo2 block they point to, see treeValue
+// keys of both trees are checked first, ErrUnsortedTree is returned before loop is called
+// if keys of either tree are not in CompareBytes order
+func DiffTree(a, b BomStore, name string, loop func(key []byte, change TreeChange) (stop bool)) error {
+	if err := checkSorted(a, name); err != nil {
+		return err
+	}
+	if err := checkSorted(b, name); err != nil {
+		return err
+	}
+	ia, err := a.Iterator(name, nil)
+	if err != nil {
+		return err
+	}
+	ib, err := b.Iterator(name, nil)
+	if err != nil {
+		return err
+	}
+	na, nb := ia.Next(), ib.Next()
+	for na || nb {
+		var key []byte
+		var change TreeChange
+		c := 0
+		switch {
+		case !na:
+			c = 1
+		case !nb:
+			c = -1
+		default:
+			c = bytes.Compare(ia.Key(), ib.Key())
+		}
+		switch {
+		case c < 0:
+			key, change = ia.Key(), TreeKeyRemoved
+		case c > 0:
+			key, change = ib.Key(), TreeKeyAdded
+		default:
+			key = ia.Key()
+			same, err := sameValue(a, b, ia, ib)
+			if err != nil {
+				return err
+			}
+			if !same {
+				change = TreeKeyChanged
+			}
+		}
+		if change != 0 && loop(key, change) {
+			return nil
+		}
+		if c <= 0 {
+			na = ia.Next()
+		}
+		if c >= 0 {
+			nb = ib.Next()
+		}
+	}
+	if ia.Err() != nil {
+		return ia.Err()
+	}
+	return ib.Err()
+}
+
+// checkSorted: ErrUnsortedTree if keys of named tree are not in CompareBytes order, values are not read
+func checkSorted(b BomStore, name string) error {
+	it, err := b.Iterator(name, nil)
+	if err != nil {
+		return err
+	}
+	var last []byte
+	for it.Next() {
+		if last != nil && bytes.Compare(it.Key(), last) <= 0 {
+			return withVar(ErrUnsortedTree, name)
+		}
+		last = append(last[:0], it.Key()...)
+	}
+	return it.Err()
+}
+
+// sameValue: values of current entries of iterators of a and b are the same, see treeValue
+func sameValue(a, b BomStore, ia, ib *TreeIterator) (bool, error) {
+	ra, rb := ia.Value(), ib.Value()
+	if ra == nil {
+		return false, ia.Err()
+	}
+	if rb == nil {
+		return false, ib.Err()
+	}
+	da, err := treeValue(a, ia.name, ra)
+	if err != nil {
+		return false, err
+	}
+	db, err := treeValue(b, ib.name, rb)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(da, db), nil
+}
+
+// treeValue: bytes of tree value to compare,
+// value of installer 'Paths' is BOMPathInfo1, its block index differs between files,
+// so it is replaced by the BOMPathInfo2 block it points to, after the id
+func treeValue(b BomStore, name string, r io.Reader) ([]byte, error) {
+	d, err := ioutil.ReadAll(r)
+	if err != nil || name != "Paths" || len(d) != 8 {
+		return d, err
+	}
+	info, err := b.ReadBlockIndex(binary.BigEndian.Uint32(d[4:]))
+	if err != nil {
+		return nil, err
+	}
+	v, err := ioutil.ReadAll(info)
+	if err != nil {
+		return nil, err
+	}
+	return append(d[:4], v...), nil
+}
+
+// treeHashes: hash of value of every key in tree, for trees not in CompareBytes order
+func treeHashes(b BomStore, name string) (map[string][sha256.Size]byte, error) {
+	m := map[string][sha256.Size]byte{}
+	if err := b.ReadTree(name, func(k io.Reader, d io.Reader) error {
+		key, err := ioutil.ReadAll(k)
+		if err != nil {
+			return err
+		}
+		v, err := treeValue(b, name, d)
+		if err != nil {
+			return err
+		}
+		m[string(key)] = sha256.Sum256(v)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func diffTree(a, b BomStore, name string) (*TreeDiff, error) {
+	t := &TreeDiff{Var: name}
+	err := DiffTree(a, b, name, func(key []byte, change TreeChange) (stop bool) {
+		switch change {
+		case TreeKeyAdded:
+			t.Added = append(t.Added, key)
+		case TreeKeyRemoved:
+			t.Removed = append(t.Removed, key)
+		case TreeKeyChanged:
+			t.Changed = append(t.Changed, key)
+		}
+		return false
+	})
+	if errors.Is(err, ErrUnsortedTree) {
+		return diffHashes(a, b, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// diffHashes: compare trees by maps of value hashes, memory grows with number of keys
+func diffHashes(a, b BomStore, name string) (*TreeDiff, error) {
+	ha, err := treeHashes(a, name)
+	if err != nil {
+		return nil, err
+	}
+	hb, err := treeHashes(b, name)
+	if err != nil {
+		return nil, err
+	}
+	t := &TreeDiff{Var: name}
+	for k, va := range ha {
+		vb, ok := hb[k]
+		if !ok {
+			t.Removed = append(t.Removed, []byte(k))
+		} else if va != vb {
+			t.Changed = append(t.Changed, []byte(k))
+		}
+	}
+	for k := range hb {
+		if _, ok := ha[k]; !ok {
+			t.Added = append(t.Added, []byte(k))
+		}
+	}
+	for _, list := range [][][]byte{t.Added, t.Removed, t.Changed} {
+		sort.Slice(list, func(i, j int) bool {
+			return bytes.Compare(list[i], list[j]) < 0
+		})
+	}
+	return t, nil
+}
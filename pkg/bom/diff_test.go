@@ -0,0 +1,214 @@
+package bom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	src, err := ioutil.ReadFile("test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewReaderAt(bytes.NewReader(src), int64(len(src)))
+	if err := a.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Diff(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Equal() {
+		t.Fatalf("same file: %+v", d)
+	}
+
+	e, err := NewEditor(bytes.NewReader(src), int64(len(src)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ReplaceBlock("CARHEADER", []byte("header")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ReplaceTreeValue("APPEARANCEKEYS", []byte("UIAppearanceAny"), nil, []byte{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddVar("NEW", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DeleteVar("BITMAPKEYS"); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := openEdited(t, e)
+
+	d, err = Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DiffReport{
+		VarsAdded:   []string{"NEW"},
+		VarsRemoved: []string{"BITMAPKEYS"},
+		Blocks:      []BlockDiff{{Var: "CARHEADER", OldLength: 436, NewLength: 6}},
+		Trees:       []TreeDiff{{Var: "APPEARANCEKEYS", Changed: [][]byte{[]byte("UIAppearanceAny")}}},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Fatalf("diff: %+v", d)
+	}
+}
+
+// buildDiffTree: file with tree "TREE" of items, pages of blockSize bytes, 0 means DefaultBlockSize
+func buildDiffTree(t *testing.T, items []TreeItem, blockSize uint32) BomStore {
+	w := NewWriter()
+	if blockSize > 0 {
+		w.BlockSize = blockSize
+	}
+	if _, err := w.WriteTree("TREE", items); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := NewReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDiffTreeKeys(t *testing.T) {
+	a := buildDiffTree(t, []TreeItem{{Key: []byte("a"), Value: []byte("1")}, {Key: []byte("b"), Value: []byte("2")}, {Key: []byte("c"), Value: []byte("3")}}, 0)
+	b := buildDiffTree(t, []TreeItem{{Key: []byte("b"), Value: []byte("2")}, {Key: []byte("c"), Value: []byte("4")}, {Key: []byte("d"), Value: []byte("5")}}, 0)
+
+	d, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TreeDiff{{
+		Var:     "TREE",
+		Added:   [][]byte{[]byte("d")},
+		Removed: [][]byte{[]byte("a")},
+		Changed: [][]byte{[]byte("c")},
+	}}
+	if !reflect.DeepEqual(d.Trees, want) || len(d.Blocks) != 0 {
+		t.Fatalf("diff: %+v", d)
+	}
+
+	// stop after first difference
+	n := 0
+	if err := DiffTree(a, b, "TREE", func(key []byte, change TreeChange) (stop bool) {
+		n++
+		if string(key) != "a" || change != TreeKeyRemoved {
+			t.Errorf("first difference: %q %v", key, change)
+		}
+		return true
+	}); err != nil || n != 1 {
+		t.Fatalf("stop: %v %d", err, n)
+	}
+
+	// unsorted trees are compared by hashes, DiffTree reports nothing
+	u := buildDiffTree(t, []TreeItem{{Key: []byte("d"), Value: []byte("5")}, {Key: []byte("b"), Value: []byte("2")}, {Key: []byte("c"), Value: []byte("4")}}, 0)
+	n = 0
+	if err := DiffTree(a, u, "TREE", func(key []byte, change TreeChange) (stop bool) {
+		n++
+		return false
+	}); !errors.Is(err, ErrUnsortedTree) || n != 0 {
+		t.Fatalf("unsorted: %v %d", err, n)
+	}
+	d, err = Diff(a, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.Trees, want) {
+		t.Fatalf("unsorted diff: %+v", d)
+	}
+}
+
+// same entries with pages of different sizes are equal
+func TestDiffBlockSize(t *testing.T) {
+	items := []TreeItem{}
+	for i := 0; i < 500; i++ {
+		items = append(items, TreeItem{Key: []byte(fmt.Sprintf("key%04d", i)), Value: []byte(fmt.Sprint(i))})
+	}
+	a := buildDiffTree(t, items, 12+2*8)
+	b := buildDiffTree(t, items, 0)
+	ea, err := a.TreeEntry("TREE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eb, err := b.TreeEntry("TREE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ea.BlockSize == eb.BlockSize {
+		t.Fatalf("block size: %d", ea.BlockSize)
+	}
+
+	d, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Equal() {
+		t.Fatalf("diff: %+v", d)
+	}
+
+	items[250].Value = []byte("changed")
+	d, err = Diff(a, buildDiffTree(t, items, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TreeDiff{{Var: "TREE", Changed: [][]byte{[]byte("key0250")}}}
+	if !reflect.DeepEqual(d.Trees, want) {
+		t.Fatalf("diff: %+v", d)
+	}
+}
+
+// buildDiffPaths: installer bom with paths, pad blocks added first to shift block indexes
+func buildDiffPaths(t *testing.T, pad int, paths []testPath) BomStore {
+	w := NewWriter()
+	for i := 0; i < pad; i++ {
+		w.AddBlock([]byte("pad"))
+	}
+	writeTestPaths(w, paths)
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := NewReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Paths values are compared by the BOMPathInfo2 they point to
+func TestDiffPaths(t *testing.T) {
+	paths := []testPath{
+		{id: 1, parent: 0, name: ".", info: PathInfo2{Type: PathTypeDir, Mode: 040755}},
+		{id: 2, parent: 1, name: "bin", info: PathInfo2{Type: PathTypeDir, Mode: 040755}},
+		{id: 3, parent: 1, name: "ls", info: PathInfo2{Type: PathTypeFile, Mode: 0100755, Size: 300}},
+	}
+	a := buildDiffPaths(t, 0, paths)
+
+	d, err := Diff(a, buildDiffPaths(t, 3, paths))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Equal() {
+		t.Fatalf("shifted blocks: %+v", d)
+	}
+
+	paths[2].info.Mode = 0100644
+	d, err = Diff(a, buildDiffPaths(t, 3, paths))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TreeDiff{{Var: "Paths", Changed: [][]byte{{0, 0, 0, 1, 'l', 's', 0}}}}
+	if !reflect.DeepEqual(d.Trees, want) {
+		t.Fatalf("mode: %+v", d)
+	}
+}
@@ -0,0 +1,454 @@
+package bom
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/iineva/bom/pkg/helper"
+)
+
+var (
+	ErrVarNotFound = errors.New("var not found")
+)
+
+type EditMode int
+
+const (
+	// reuse free space, compact when more than a quarter of file would be free
+	EditAuto = EditMode(iota)
+	// keep unchanged blocks in place, put changed blocks into free space or at end of file
+	EditReuse
+	// rewrite all blocks one after another, without free space
+	EditCompact
+)
+
+// Editor: change blocks and vars of existing BOMStore file, block indexes are kept
+// trees are read from the original file, so changes of tree pages are not seen by tree methods
+//
+//	e, err := bom.NewEditor(f, size)
+//	e.ReplaceBlock("CARHEADER", header)
+//	e.ReplaceTreeValue("FACETKEYS", []byte("AppIcon"), nil, value)
+//	e.WriteTo(out)
+type Editor struct {
+	Mode EditMode
+
+	b *bom
+	// block table of original file, null after delete
+	pointers []Pointer
+	// content of changed and added blocks
+	data map[uint32][]byte
+	vars []Var
+	// free extents of original file and deleted blocks
+	free []Pointer
+}
+
+// NewEditor: parse r and create editor of it
+func NewEditor(r io.ReaderAt, size int64) (*Editor, error) {
+	b := &bom{r: r, size: size, opts: DefaultOptions}
+	if err := b.Parse(); err != nil {
+		return nil, err
+	}
+	e := &Editor{
+		b:        b,
+		pointers: make([]Pointer, len(b.blockTable.BlockPointers)),
+		data:     map[uint32][]byte{},
+		vars:     append([]Var{}, b.vars...),
+	}
+	for i, p := range b.blockTable.BlockPointers {
+		e.pointers[i] = *p
+	}
+	e.free = e.validFree(b.FreeList())
+	return e, nil
+}
+
+// validFree: drop free extents out of file or overlapping used space
+func (e *Editor) validFree(list []Pointer) []Pointer {
+	h := e.b.header
+	used := []Pointer{
+		{Address: 0, Length: headerSize},
+		{Address: h.IndexOffset, Length: h.IndexLength},
+		{Address: h.VarsOffset, Length: h.VarsLength},
+	}
+	for i, p := range e.pointers {
+		if i > 0 && p.Length > 0 {
+			used = append(used, p)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool {
+		return used[i].Address < used[j].Address
+	})
+
+	free := []Pointer{}
+	for _, p := range list {
+		start, end := uint64(p.Address), uint64(p.Address)+uint64(p.Length)
+		if p.Length == 0 || end > uint64(e.b.size) {
+			continue
+		}
+		// first used extent ending after start
+		i := sort.Search(len(used), func(i int) bool {
+			return uint64(used[i].Address)+uint64(used[i].Length) > start
+		})
+		ok := true
+		for ; i < len(used) && uint64(used[i].Address) < end; i++ {
+			if used[i].Length > 0 {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			free = append(free, p)
+		}
+	}
+	return coalesce(free)
+}
+
+// exists: index is a non-null block
+func (e *Editor) exists(index uint32) bool {
+	if index == 0 || index >= uint32(len(e.pointers)) {
+		return false
+	}
+	if _, ok := e.data[index]; ok {
+		return true
+	}
+	p := e.pointers[index]
+	return p.Address != 0 || p.Length != 0
+}
+
+func (e *Editor) varIndex(name string) (int, bool) {
+	for i, v := range e.vars {
+		if v.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// SetBlock: replace content of block index
+func (e *Editor) SetBlock(index uint32, data []byte) error {
+	if !e.exists(index) {
+		return &Error{Op: "set block", Block: index, Err: ErrBlockNotFound}
+	}
+	e.data[index] = data
+	return nil
+}
+
+// ReplaceBlock: replace content of named block
+func (e *Editor) ReplaceBlock(name string, data []byte) error {
+	i, ok := e.varIndex(name)
+	if !ok {
+		return &Error{Op: "replace block", Var: name, Err: ErrVarNotFound}
+	}
+	return e.SetBlock(e.vars[i].Index, data)
+}
+
+// ReplaceTreeValue: replace value of key in named tree, cmp nil means CompareBytes
+func (e *Editor) ReplaceTreeValue(name string, key []byte, cmp KeyCompare, data []byte) error {
+	if cmp == nil {
+		cmp = CompareBytes
+	}
+	entry, err := e.b.treeEntry(name)
+	if err != nil {
+		return err
+	}
+	pi, err := e.b.lookup(entry, key, cmp)
+	if err != nil {
+		return withVar(err, name)
+	}
+	return e.SetBlock(pi.ValueIndex, data)
+}
+
+// AddVar: add named block, returns block table index
+// null entries of block table are used first
+func (e *Editor) AddVar(name string, data []byte) (uint32, error) {
+	if len(name) > 0xff {
+		return 0, ErrNameTooLong
+	}
+	if _, ok := e.varIndex(name); ok {
+		return 0, ErrNameExists
+	}
+	index := uint32(0)
+	for i := 1; i < len(e.pointers); i++ {
+		if !e.exists(uint32(i)) {
+			index = uint32(i)
+			break
+		}
+	}
+	if index == 0 {
+		index = uint32(len(e.pointers))
+		e.pointers = append(e.pointers, Pointer{})
+	}
+	e.data[index] = data
+	e.vars = append(e.vars, Var{Index: index, Length: uint8(len(name)), Name: name})
+	return index, nil
+}
+
+// DeleteVar: remove var and free its block, for a tree all pages, keys and values of the tree
+// blocks referenced from inside values, like BOMPathInfo2 of 'Paths', are kept
+func (e *Editor) DeleteVar(name string) error {
+	i, ok := e.varIndex(name)
+	if !ok {
+		return &Error{Op: "delete var", Var: name, Err: ErrVarNotFound}
+	}
+	index := e.vars[i].Index
+	e.vars = append(e.vars[:i], e.vars[i+1:]...)
+
+	blocks := []uint32{index}
+	if _, changed := e.data[index]; !changed {
+		if entry, err := e.b.readTreeEntry(index); err == nil && entry.Tag.String() == "tree" {
+			tree, err := e.treeBlocks(entry)
+			if err != nil {
+				return withVar(err, name)
+			}
+			blocks = append(blocks, tree...)
+		}
+	}
+
+	// blocks still referenced by other vars are kept
+	for _, v := range e.vars {
+		for j, b := range blocks {
+			if b == v.Index {
+				blocks[j] = 0
+			}
+		}
+	}
+	for _, b := range blocks {
+		e.freeBlock(b)
+	}
+	e.free = coalesce(e.free)
+	return nil
+}
+
+// treeBlocks: pages, keys and values of tree
+func (e *Editor) treeBlocks(entry *TreeEntry) ([]uint32, error) {
+	blocks := []uint32{}
+	visited := map[uint32]bool{}
+	var walk func(index uint32, depth int) error
+	walk = func(index uint32, depth int) error {
+		if depth > e.b.opts.MaxTreeDepth {
+			return e.b.blockError("read tree page", index, &LimitError{Limit: "MaxTreeDepth", Value: int64(depth), Max: int64(e.b.opts.MaxTreeDepth)})
+		}
+		if visited[index] {
+			return e.b.blockError("read tree page", index, ErrTreeCycle)
+		}
+		visited[index] = true
+		tree, err := e.b.readPage(index)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, index)
+		for _, pi := range tree.List {
+			if !entry.InlineKeys() {
+				blocks = append(blocks, pi.KeyIndex)
+			}
+			if tree.IsLeaf != 0 {
+				blocks = append(blocks, pi.ValueIndex)
+			} else if err := walk(pi.ValueIndex, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(entry.Index, 1); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// freeBlock: make block null, space of original block becomes free
+func (e *Editor) freeBlock(index uint32) {
+	if !e.exists(index) {
+		return
+	}
+	delete(e.data, index)
+	if p := e.pointers[index]; p.Length > 0 {
+		e.free = append(e.free, p)
+	}
+	e.pointers[index] = Pointer{}
+}
+
+// WriteTo: write edited file
+func (e *Editor) WriteTo(out io.Writer) (int64, error) {
+	var d []byte
+	var err error
+	switch e.Mode {
+	case EditCompact:
+		d, err = e.compact()
+	default:
+		var free int64
+		d, free, err = e.reuse()
+		if err == nil && e.Mode == EditAuto && free > int64(len(d))/4 {
+			d, err = e.compact()
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := out.Write(d)
+	return int64(n), err
+}
+
+// block: content of block index, changed or from original file,
+// blocks of original file are checked against file size before they are read
+func (e *Editor) block(index uint32) ([]byte, error) {
+	if d, ok := e.data[index]; ok {
+		return d, nil
+	}
+	return e.b.blockData(index)
+}
+
+// compact: header, blocks, block table with an empty free list, vars
+func (e *Editor) compact() ([]byte, error) {
+	d := make([]byte, headerSize)
+	pointers := make([]Pointer, len(e.pointers))
+	for i := range e.pointers {
+		if !e.exists(uint32(i)) {
+			continue
+		}
+		v, err := e.block(uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		pointers[i] = Pointer{Address: uint32(len(d)), Length: uint32(len(v))}
+		d = append(d, v...)
+	}
+
+	indexOffset := len(d)
+	d = append(d, encodeIndex(pointers, nil)...)
+	varsOffset := len(d)
+	d = append(d, encodeVars(e.vars)...)
+	return e.finish(d, pointers, indexOffset, varsOffset-indexOffset, varsOffset, len(d)-varsOffset)
+}
+
+// reuse: keep original layout, returns file and its free bytes
+// unchanged blocks of block table are copied to their address, header, block table and vars are written again
+func (e *Editor) reuse() ([]byte, int64, error) {
+	d := make([]byte, e.b.size)
+	for i, p := range e.pointers {
+		if _, ok := e.data[uint32(i)]; ok || !e.exists(uint32(i)) {
+			continue
+		}
+		v, err := e.block(uint32(i))
+		if err != nil {
+			return nil, 0, err
+		}
+		copy(d[p.Address:], v)
+	}
+	pointers := append([]Pointer{}, e.pointers...)
+	free := append([]Pointer{}, e.free...)
+
+	// alloc: best fit free extent, or end of file
+	alloc := func(n int) uint32 {
+		best := -1
+		for i, p := range free {
+			if int(p.Length) >= n && n > 0 && (best < 0 || p.Length < free[best].Length) {
+				best = i
+			}
+		}
+		if best < 0 {
+			addr := uint32(len(d))
+			d = append(d, make([]byte, n)...)
+			return addr
+		}
+		addr := free[best].Address
+		free[best].Address += uint32(n)
+		free[best].Length -= uint32(n)
+		if free[best].Length == 0 {
+			free = append(free[:best], free[best+1:]...)
+		}
+		return addr
+	}
+	// place: write v into old extent if it fits, otherwise free old extent and alloc
+	place := func(old Pointer, v []byte) Pointer {
+		if old.Address != 0 && uint64(len(v)) <= uint64(old.Length) {
+			if tail := old.Length - uint32(len(v)); tail > 0 {
+				free = append(free, Pointer{Address: old.Address + uint32(len(v)), Length: tail})
+			}
+			copy(d[old.Address:], v)
+			return Pointer{Address: old.Address, Length: uint32(len(v))}
+		}
+		if old.Length > 0 {
+			free = coalesce(append(free, old))
+		}
+		addr := alloc(len(v))
+		copy(d[addr:], v)
+		return Pointer{Address: addr, Length: uint32(len(v))}
+	}
+
+	changed := make([]uint32, 0, len(e.data))
+	for i := range e.data {
+		changed = append(changed, i)
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return changed[i] < changed[j]
+	})
+	for _, i := range changed {
+		pointers[i] = place(pointers[i], e.data[i])
+	}
+
+	h := e.b.header
+	vars := place(Pointer{Address: h.VarsOffset, Length: h.VarsLength}, encodeVars(e.vars))
+
+	// index stays if it fits, else moves to end of file and old index becomes free
+	indexOffset, indexLength := h.IndexOffset, h.IndexLength
+	free = coalesce(free)
+	if uint64(len(encodeIndex(pointers, free))) > uint64(indexLength) {
+		free = coalesce(append(free, Pointer{Address: indexOffset, Length: indexLength}))
+		indexOffset, indexLength = uint32(len(d)), uint32(len(encodeIndex(pointers, free)))
+		d = append(d, make([]byte, indexLength)...)
+	}
+	index := make([]byte, indexLength)
+	copy(index, encodeIndex(pointers, free))
+	copy(d[indexOffset:], index)
+
+	freeBytes := int64(0)
+	for _, p := range free {
+		freeBytes += int64(p.Length)
+	}
+	d, err := e.finish(d, pointers, int(indexOffset), int(indexLength), int(vars.Address), int(vars.Length))
+	return d, freeBytes, err
+}
+
+// finish: write header into d
+func (e *Editor) finish(d []byte, pointers []Pointer, indexOffset, indexLength, varsOffset, varsLength int) ([]byte, error) {
+	if int64(len(d)) > math.MaxUint32 {
+		return nil, ErrFileTooLarge
+	}
+	n := uint32(0)
+	for i, p := range pointers {
+		if i > 0 && (p.Address != 0 || p.Length != 0) {
+			n++
+		}
+	}
+	h := *e.b.header
+	h.Magic = helper.NewString8(HeaderMagic)
+	h.NumberOfBlocks = n
+	h.IndexOffset = uint32(indexOffset)
+	h.IndexLength = uint32(indexLength)
+	h.VarsOffset = uint32(varsOffset)
+	h.VarsLength = uint32(varsLength)
+	copy(d, encodeHeader(&h))
+	return d, nil
+}
+
+// coalesce: sort free extents by address and merge neighbours
+func coalesce(list []Pointer) []Pointer {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Address < list[j].Address
+	})
+	out := []Pointer{}
+	for _, p := range list {
+		if p.Length == 0 {
+			continue
+		}
+		if n := len(out); n > 0 && uint64(out[n-1].Address)+uint64(out[n-1].Length) >= uint64(p.Address) {
+			if end := p.Address + p.Length; end > out[n-1].Address+out[n-1].Length {
+				out[n-1].Length = end - out[n-1].Address
+			}
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func openEdited(t *testing.T, e *Editor) (BomStore, []byte) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if _, err := e.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	d := buf.Bytes()
+	b := NewReaderAt(bytes.NewReader(d), int64(len(d)))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	r := b.Verify()
+	if !r.OK() {
+		t.Fatalf("verify: %v", r.Problems)
+	}
+	return b, d
+}
+
+func readAll(t *testing.T, b BomParser, name string) []byte {
+	t.Helper()
+	r, err := b.ReadBlock(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestEditor(t *testing.T) {
+	src, err := ioutil.ReadFile("test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []EditMode{EditAuto, EditReuse, EditCompact} {
+		e, err := NewEditor(bytes.NewReader(src), int64(len(src)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Mode = mode
+
+		header := bytes.Repeat([]byte{1}, 436)
+		if err := e.ReplaceBlock("CARHEADER", header); err != nil {
+			t.Fatal(err)
+		}
+		// larger than the block, goes into free space or end of file
+		metadata := bytes.Repeat([]byte{2}, 1500)
+		if err := e.ReplaceBlock("EXTENDED_METADATA", metadata); err != nil {
+			t.Fatal(err)
+		}
+		value := []byte("new value")
+		if err := e.ReplaceTreeValue("APPEARANCEKEYS", []byte("UIAppearanceAny"), nil, value); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.AddVar("NEW", []byte("new block")); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.DeleteVar("BITMAPKEYS"); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.DeleteVar("NOTFOUND"); !errors.Is(err, ErrVarNotFound) {
+			t.Fatalf("delete not found: %v", err)
+		}
+
+		b, d := openEdited(t, e)
+		if v := readAll(t, b, "CARHEADER"); !bytes.Equal(v, header) {
+			t.Fatalf("CARHEADER: %v", v)
+		}
+		if v := readAll(t, b, "EXTENDED_METADATA"); !bytes.Equal(v, metadata) {
+			t.Fatalf("EXTENDED_METADATA: %v", v)
+		}
+		if v := readAll(t, b, "NEW"); string(v) != "new block" {
+			t.Fatalf("NEW: %v", v)
+		}
+		r, err := b.Lookup("APPEARANCEKEYS", []byte("UIAppearanceAny"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := ioutil.ReadAll(r); !bytes.Equal(v, value) {
+			t.Fatalf("APPEARANCEKEYS: %v", v)
+		}
+		if _, err := b.ReadBlock("BITMAPKEYS"); err != ErrNameNotMatch {
+			t.Fatalf("BITMAPKEYS: %v", err)
+		}
+		n := 0
+		if err := b.ReadTree("RENDITIONS", func(k, v io.Reader) error {
+			n++
+			return nil
+		}); err != nil || n == 0 {
+			t.Fatalf("RENDITIONS: %v %v", n, err)
+		}
+
+		s := b.Stats()
+		switch mode {
+		case EditCompact:
+			if s.FreeBytes != 0 || s.SlackBytes != 0 {
+				t.Fatalf("compact stats: %+v", s)
+			}
+		case EditReuse:
+			// unchanged blocks stay in place
+			if len(d) < len(src) || s.FreeBytes == 0 {
+				t.Fatalf("reuse stats: %+v", s)
+			}
+		}
+	}
+}
+
+func TestEditorIndexGrows(t *testing.T) {
+	d := buildTestBom([][]byte{[]byte("a")}, []Var{{Index: 1, Name: "A"}})
+	e, err := NewEditor(bytes.NewReader(d), int64(len(d)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Mode = EditReuse
+	for _, name := range []string{"B", "C", "D"} {
+		if _, err := e.AddVar(name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := e.AddVar("A", nil); err != ErrNameExists {
+		t.Fatalf("AddVar exists: %v", err)
+	}
+	if err := e.DeleteVar("A"); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := openEdited(t, e)
+	for _, name := range []string{"B", "C", "D"} {
+		if v := readAll(t, b, name); string(v) != name {
+			t.Fatalf("%v: %v", name, v)
+		}
+	}
+	// old block table and block A are free now
+	if len(b.FreeList()) == 0 {
+		t.Fatal("empty free list")
+	}
+}
+
+// block pointers are checked against file size before blocks are read
+func TestEditorBlockOutOfFile(t *testing.T) {
+	d := buildTestBom([][]byte{[]byte("a"), []byte("b")}, []Var{{Index: 1, Name: "A"}, {Index: 2, Name: "B"}})
+	offset := binary.BigEndian.Uint32(d[16:])
+	binary.BigEndian.PutUint32(d[offset+4+2*8+4:], 1<<31)
+
+	for _, mode := range []EditMode{EditReuse, EditCompact} {
+		e, err := NewEditor(bytes.NewReader(d), int64(len(d)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Mode = mode
+		if _, err := e.WriteTo(ioutil.Discard); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package bom
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrBadMagic  = errors.New("bad magic")
+	ErrTruncated = errors.New("truncated")
+)
+
+// Error: error of reading one part of file, with where it happened
+// errors.Is(err, ErrTruncated) is true when the cause is an unexpected end of file
+type Error struct {
+	// what was read, like "header", "block table", "tree page"
+	Op string
+	// var name, empty if unknown
+	Var string
+	// block table index, 0 if not about a block
+	Block uint32
+	// file offset of the read, 0 if unknown
+	Offset int64
+	Err    error
+}
+
+func (e *Error) Error() string {
+	s := "bom: " + e.Op
+	if e.Var != "" {
+		s += fmt.Sprintf(" var '%s'", e.Var)
+	}
+	if e.Block != 0 {
+		s += fmt.Sprintf(" block %d", e.Block)
+	}
+	if e.Offset != 0 {
+		s += fmt.Sprintf(" offset 0x%x", e.Offset)
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrTruncated && (errors.Is(e.Err, io.ErrUnexpectedEOF) || errors.Is(e.Err, io.EOF))
+}
+
+// blockError: error of block index, offset is the block address
+func (b *bom) blockError(op string, index uint32, err error) error {
+	e := &Error{Op: op, Block: index, Err: err}
+	if b.blockTable != nil && index < uint32(len(b.blockTable.BlockPointers)) {
+		e.Offset = int64(b.blockTable.BlockPointers[index].Address)
+	}
+	return e
+}
+
+// withVar: add var name to err, errors of callbacks are not *Error and stay as is
+func withVar(err error, name string) error {
+	e, ok := err.(*Error)
+	if !ok || e.Var != "" {
+		return err
+	}
+	c := *e
+	c.Var = name
+	return &c
+}
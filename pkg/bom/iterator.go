@@ -0,0 +1,294 @@
+package bom
+
+import (
+	"io"
+)
+
+// TreeIterator: cursor over tree entries in key order
+// it can be paused, resumed and moved in both directions through Forward and Backward pointers
+//
+//	it, err := b.Iterator("FACETKEYS", nil)
+//	it.SetPrefix([]byte("AppIcon"))
+//	for it.Next() {
+//		// it.Key(), it.Value()
+//	}
+//	err = it.Err()
+type TreeIterator struct {
+	b    *bom
+	name string
+	cmp  KeyCompare
+	root uint32
+	// keys are KeyIndex, see TreeEntry.InlineKeys
+	inline bool
+
+	// range limits, lower is inclusive, upper is exclusive, nil means unbounded
+	lower []byte
+	upper []byte
+
+	page  *Tree
+	index uint32 // block index of page
+	pos   int
+	key   []byte
+	// pages visited since the cursor started moving in direction dir, to detect pointer cycles
+	// direction changes start a new set, so walking back and forth never looks like a cycle
+	dir     int
+	visited map[uint32]bool
+	err     error
+}
+
+// Iterator: create cursor of named tree, cmp nil means CompareBytes
+// the cursor is not positioned, the first Next moves to the first entry, the first Prev moves to the last entry
+func (b *bom) Iterator(name string, cmp KeyCompare) (*TreeIterator, error) {
+	if cmp == nil {
+		cmp = CompareBytes
+	}
+	entry, err := b.treeEntry(name)
+	if err != nil {
+		return nil, err
+	}
+	return &TreeIterator{b: b, name: name, cmp: cmp, root: entry.Index, inline: entry.InlineKeys()}, nil
+}
+
+// SetRange: limit the cursor to keys in [start, end), nil means unbounded
+// the cursor is reset to not positioned
+func (it *TreeIterator) SetRange(start, end []byte) {
+	it.lower, it.upper = start, end
+	it.reset()
+}
+
+// SetPrefix: limit the cursor to keys starting with prefix
+// the tree must be sorted with CompareBytes
+func (it *TreeIterator) SetPrefix(prefix []byte) {
+	it.SetRange(prefix, prefixEnd(prefix))
+}
+
+// Valid: cursor is positioned at an entry
+func (it *TreeIterator) Valid() bool {
+	return it.err == nil && it.key != nil
+}
+
+// Key: key of current entry
+func (it *TreeIterator) Key() []byte {
+	return it.key
+}
+
+// Value: value block of current entry, nil if the block can not be read, see Err
+func (it *TreeIterator) Value() io.Reader {
+	if !it.Valid() {
+		return nil
+	}
+	r, err := it.b.blockReader(it.page.List[it.pos].ValueIndex)
+	if err != nil {
+		it.err = err
+		return nil
+	}
+	return r
+}
+
+// Err: error stopped the cursor
+func (it *TreeIterator) Err() error {
+	if it.err == nil {
+		return nil
+	}
+	return withVar(it.err, it.name)
+}
+
+// First: move to the first entry in range
+func (it *TreeIterator) First() bool {
+	if it.lower != nil {
+		return it.Seek(it.lower)
+	}
+	it.reset()
+	index, err := it.b.descend(it.root, func(tree *Tree) (int, error) {
+		return 0, nil
+	})
+	if !it.load(index, err) {
+		return false
+	}
+	it.pos = 0
+	return it.forward()
+}
+
+// Last: move to the last entry in range
+func (it *TreeIterator) Last() bool {
+	if it.upper != nil {
+		if !it.seek(it.upper) {
+			return false
+		}
+		it.pos--
+		return it.backward()
+	}
+	it.reset()
+	index, err := it.b.descend(it.root, func(tree *Tree) (int, error) {
+		return len(tree.List) - 1, nil
+	})
+	if !it.load(index, err) {
+		return false
+	}
+	it.pos = len(it.page.List) - 1
+	return it.backward()
+}
+
+// Seek: move to the first entry whose key >= key
+func (it *TreeIterator) Seek(key []byte) bool {
+	if it.lower != nil && it.cmp(key, it.lower) < 0 {
+		key = it.lower
+	}
+	if !it.seek(key) {
+		return false
+	}
+	return it.forward()
+}
+
+// Next: move to next entry, or the first entry if the cursor is not positioned
+func (it *TreeIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.page == nil {
+		return it.First()
+	}
+	if it.key == nil {
+		return false
+	}
+	it.pos++
+	return it.forward()
+}
+
+// Prev: move to previous entry, or the last entry if the cursor is not positioned
+func (it *TreeIterator) Prev() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.page == nil {
+		return it.Last()
+	}
+	if it.key == nil {
+		return false
+	}
+	it.pos--
+	return it.backward()
+}
+
+func (it *TreeIterator) reset() {
+	it.page = nil
+	it.pos = 0
+	it.key = nil
+	it.dir = 0
+	it.visited = nil
+	it.err = nil
+}
+
+// seek: place cursor before the first entry whose key >= key, without range check
+func (it *TreeIterator) seek(key []byte) bool {
+	it.reset()
+	index, err := it.b.findLeaf(it.root, key, it.cmp, it.inline)
+	if !it.load(index, err) {
+		return false
+	}
+	i, _, err := it.b.searchPage(it.page, key, it.cmp, it.inline)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.pos = i
+	return true
+}
+
+// load: make page index current page
+func (it *TreeIterator) load(index uint32, err error) bool {
+	if err != nil {
+		it.err = err
+		return false
+	}
+	page, err := it.b.readPage(index)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page = page
+	it.index = index
+	return true
+}
+
+// move: load next page in direction dir, 1 is Forward and -1 is Backward
+// like ReadTree, a page visited twice in one direction is a cycle
+func (it *TreeIterator) move(index uint32, dir int) bool {
+	if it.dir != dir {
+		it.dir = dir
+		it.visited = map[uint32]bool{it.index: true}
+	}
+	if it.visited[index] {
+		it.err = it.b.blockError("read tree page", index, ErrTreeCycle)
+		return false
+	}
+	it.visited[index] = true
+	return it.load(index, nil)
+}
+
+// forward: settle on the first entry at or after current position
+func (it *TreeIterator) forward() bool {
+	it.key = nil
+	for it.pos >= len(it.page.List) {
+		if it.page.Forward == 0 {
+			return false
+		}
+		if !it.move(it.page.Forward, 1) {
+			return false
+		}
+		it.pos = 0
+	}
+	if !it.readKey() {
+		return false
+	}
+	if it.upper != nil && it.cmp(it.key, it.upper) >= 0 {
+		it.key = nil
+		return false
+	}
+	return true
+}
+
+// backward: settle on the first entry at or before current position
+func (it *TreeIterator) backward() bool {
+	it.key = nil
+	for it.pos < 0 {
+		if it.page.Backward == 0 {
+			return false
+		}
+		if !it.move(it.page.Backward, -1) {
+			return false
+		}
+		it.pos = len(it.page.List) - 1
+	}
+	if !it.readKey() {
+		return false
+	}
+	if it.lower != nil && it.cmp(it.key, it.lower) < 0 {
+		it.key = nil
+		return false
+	}
+	return true
+}
+
+func (it *TreeIterator) readKey() bool {
+	k, err := it.b.readKey(it.page.List[it.pos], it.inline)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.key = k
+	return true
+}
+
+// prefixEnd: the smallest key greater than all keys starting with prefix, nil if there is not
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
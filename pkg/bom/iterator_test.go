@@ -0,0 +1,174 @@
+package bom
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func iteratorKeys(it *TreeIterator, next func() bool) []string {
+	keys := []string{}
+	for next() {
+		keys = append(keys, string(it.Key()))
+	}
+	return keys
+}
+
+func TestTreeIterator(t *testing.T) {
+	b := New(bytes.NewReader(buildMultiPageTree(false)))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	it, err := b.Iterator("TREE", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := []string{"k0", "k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8"}
+	if keys := iteratorKeys(it, it.Next); !reflect.DeepEqual(all, keys) {
+		t.Fatalf("Next: %v", keys)
+	}
+
+	it.SetRange(nil, nil)
+	reversed := []string{"k8", "k7", "k6", "k5", "k4", "k3", "k2", "k1", "k0"}
+	if keys := iteratorKeys(it, it.Prev); !reflect.DeepEqual(reversed, keys) {
+		t.Fatalf("Prev: %v", keys)
+	}
+
+	// seek into the middle of a page, then walk both ways across pages
+	if !it.Seek([]byte("k3")) || string(it.Key()) != "k3" {
+		t.Fatalf("Seek k3: %s", it.Key())
+	}
+	d, err := ioutil.ReadAll(it.Value())
+	if err != nil || string(d) != "v3" {
+		t.Fatalf("value: %s %v", d, err)
+	}
+	if !it.Prev() || string(it.Key()) != "k2" {
+		t.Fatalf("Prev: %s", it.Key())
+	}
+	if !it.Next() || !it.Next() || string(it.Key()) != "k4" {
+		t.Fatalf("Next: %s", it.Key())
+	}
+	// seek between keys
+	if !it.Seek([]byte("k25")) || string(it.Key()) != "k3" {
+		t.Fatalf("Seek k25: %s", it.Key())
+	}
+	if it.Seek([]byte("k9")) || it.Valid() {
+		t.Fatalf("Seek k9: %s", it.Key())
+	}
+
+	it.SetRange([]byte("k2"), []byte("k6"))
+	if keys := iteratorKeys(it, it.Next); !reflect.DeepEqual([]string{"k2", "k3", "k4", "k5"}, keys) {
+		t.Fatalf("range: %v", keys)
+	}
+	it.SetRange([]byte("k2"), []byte("k6"))
+	if keys := iteratorKeys(it, it.Prev); !reflect.DeepEqual([]string{"k5", "k4", "k3", "k2"}, keys) {
+		t.Fatalf("range Prev: %v", keys)
+	}
+
+	it.SetPrefix([]byte("k"))
+	if keys := iteratorKeys(it, it.Next); !reflect.DeepEqual(all, keys) {
+		t.Fatalf("prefix: %v", keys)
+	}
+	it.SetPrefix([]byte("k5"))
+	if keys := iteratorKeys(it, it.Next); !reflect.DeepEqual([]string{"k5"}, keys) {
+		t.Fatalf("prefix k5: %v", keys)
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+}
+
+func TestTreeIteratorPrefix(t *testing.T) {
+	f, err := openTestData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b := New(f)
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	it, err := b.Iterator("FACETKEYS", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it.SetPrefix([]byte("test"))
+	if keys := iteratorKeys(it, it.Next); !reflect.DeepEqual([]string{"test", "test2", "test3"}, keys) {
+		t.Fatalf("prefix: %v", keys)
+	}
+	it.SetPrefix([]byte("AppIcon"))
+	if keys := iteratorKeys(it, it.Next); !reflect.DeepEqual([]string{"AppIcon"}, keys) {
+		t.Fatalf("prefix: %v", keys)
+	}
+}
+
+func TestTreeIteratorBackAndForth(t *testing.T) {
+	data := buildMultiPageTree(false)
+	b := New(bytes.NewReader(data))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	it, err := b.Iterator("TREE", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every round moves 4 pages, more moves than blocks without positioning again
+	blocks := len(b.(*bom).blockTable.BlockPointers)
+	walk := func(next func() bool, last string) {
+		for next() {
+			if string(it.Key()) == last {
+				return
+			}
+		}
+		t.Fatalf("stopped before %s: %v", last, it.Err())
+	}
+	for round := 0; round*4 <= blocks; round++ {
+		walk(it.Next, "k8")
+		walk(it.Prev, "k0")
+	}
+	walk(it.Next, "k8")
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+}
+
+func TestTreeIteratorValueError(t *testing.T) {
+	b := New(bytes.NewReader(buildMultiPageTree(false)))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	it, err := b.Iterator("TREE", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	it.page.List[it.pos].ValueIndex = 1000
+	if it.Value() != nil || !errors.Is(it.Err(), ErrBlockNotFound) {
+		t.Fatalf("want ErrBlockNotFound, got: %v", it.Err())
+	}
+	if it.Next() {
+		t.Fatal("Next after error")
+	}
+}
+
+func TestTreeIteratorCycle(t *testing.T) {
+	b := New(bytes.NewReader(buildMultiPageTree(true)))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	it, err := b.Iterator("TREE", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for it.Next() {
+	}
+	if !errors.Is(it.Err(), ErrTreeCycle) {
+		t.Fatalf("want ErrTreeCycle, got: %v", it.Err())
+	}
+}
@@ -0,0 +1,178 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+var (
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+// KeyCompare compare two tree keys, returns -1, 0 or +1 like bytes.Compare
+type KeyCompare func(a, b []byte) int
+
+// CompareBytes compare keys byte by byte,
+// FACETKEYS, APPEARANCEKEYS, BITMAPKEYS and RENDITIONS of Assets.car are sorted in this order,
+// RENDITIONS keys are little endian attribute values packed in KEYFORMAT order, but they are compared as raw bytes
+func CompareBytes(a, b []byte) int {
+	return bytes.Compare(a, b)
+}
+
+// CompareUint16LE compare keys as packed little endian uint16 tuples,
+// for trees sorted by attribute values instead of raw bytes
+func CompareUint16LE(a, b []byte) int {
+	for len(a) >= 2 && len(b) >= 2 {
+		x := binary.LittleEndian.Uint16(a)
+		y := binary.LittleEndian.Uint16(b)
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+		a, b = a[2:], b[2:]
+	}
+	return bytes.Compare(a, b)
+}
+
+// Lookup: find value of key in named tree
+// search from root page to leaf page by binary search, without reading other pages
+// cmp must match the order of tree keys, nil means CompareBytes
+func (b *bom) Lookup(name string, key []byte, cmp KeyCompare) (io.Reader, error) {
+	if cmp == nil {
+		cmp = CompareBytes
+	}
+
+	entry, err := b.treeEntry(name)
+	if err != nil {
+		return nil, err
+	}
+	pi, err := b.lookup(entry, key, cmp)
+	if err != nil {
+		return nil, withVar(err, name)
+	}
+	return b.blockReader(pi.ValueIndex)
+}
+
+// lookup: find leaf entry of key in tree
+func (b *bom) lookup(entry *TreeEntry, key []byte, cmp KeyCompare) (TreeIndex, error) {
+	inline := entry.InlineKeys()
+	index, err := b.findLeaf(entry.Index, key, cmp, inline)
+	if err != nil {
+		return TreeIndex{}, err
+	}
+	tree, err := b.readPage(index)
+	if err != nil {
+		return TreeIndex{}, err
+	}
+
+	pi, found, err := b.searchPage(tree, key, cmp, inline)
+	if err != nil {
+		return TreeIndex{}, err
+	}
+	if !found {
+		return TreeIndex{}, ErrKeyNotFound
+	}
+
+	return tree.List[pi], nil
+}
+
+func (b *bom) treeEntry(name string) (*TreeEntry, error) {
+	for _, v := range b.vars {
+		if v.Name == name {
+			entry, err := b.readTreeEntry(v.Index)
+			if err != nil {
+				return nil, withVar(err, name)
+			}
+			return entry, nil
+		}
+	}
+	return nil, ErrNameNotMatch
+}
+
+// findLeaf: go down from page index to the leaf page which may contain key
+func (b *bom) findLeaf(index uint32, key []byte, cmp KeyCompare, inline bool) (uint32, error) {
+	return b.descend(index, func(tree *Tree) (int, error) {
+		// branch entry key is the last key of child page
+		i, _, err := b.searchPage(tree, key, cmp, inline)
+		if err != nil {
+			return 0, err
+		}
+		if i >= len(tree.List) {
+			i = len(tree.List) - 1
+		}
+		return i, nil
+	})
+}
+
+// descend: go down from page index to a leaf page, pick returns the child entry of each branch page
+func (b *bom) descend(index uint32, pick func(tree *Tree) (int, error)) (uint32, error) {
+	visited := map[uint32]bool{index: true}
+	for depth := 1; ; depth++ {
+		tree, err := b.readPage(index)
+		if err != nil {
+			return 0, err
+		}
+		if tree.IsLeaf != 0 {
+			return index, nil
+		}
+		if depth >= b.opts.MaxTreeDepth {
+			return 0, b.blockError("read tree page", index, &LimitError{Limit: "MaxTreeDepth", Value: int64(depth + 1), Max: int64(b.opts.MaxTreeDepth)})
+		}
+		if len(tree.List) == 0 {
+			return 0, ErrKeyNotFound
+		}
+
+		i, err := pick(tree)
+		if err != nil {
+			return 0, err
+		}
+
+		index = tree.List[i].ValueIndex
+		if visited[index] {
+			return 0, b.blockError("read tree page", index, ErrTreeCycle)
+		}
+		visited[index] = true
+	}
+}
+
+// searchPage: binary search key in page entries
+// returns the index of first entry whose key >= key, and whether the keys are equal
+func (b *bom) searchPage(tree *Tree, key []byte, cmp KeyCompare, inline bool) (int, bool, error) {
+	var err error
+	i := sort.Search(len(tree.List), func(i int) bool {
+		if err != nil {
+			return true
+		}
+		k, e := b.readKey(tree.List[i], inline)
+		if e != nil {
+			err = e
+			return true
+		}
+		return cmp(k, key) >= 0
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if i >= len(tree.List) {
+		return i, false, nil
+	}
+	k, err := b.readKey(tree.List[i], inline)
+	if err != nil {
+		return 0, false, err
+	}
+	return i, cmp(k, key) == 0, nil
+}
+
+func (b *bom) readKey(pi TreeIndex, inline bool) ([]byte, error) {
+	r, err := b.keyReader(pi, inline)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
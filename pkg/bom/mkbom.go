@@ -0,0 +1,366 @@
+package bom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteInstaller: write installer BOM of paths, like mkbom
+// vars are the same as bomutils: BomInfo, Paths, HLIndex, VIndex, Size64
+// paths are full paths like "./usr/bin", parents must be listed before children
+func WriteInstaller(out io.Writer, paths []Path) error {
+	w := NewWriter()
+
+	// BomInfo, reserve first to keep the var order
+	infoIndex := w.AddBlock(nil)
+	if err := w.AddVar("BomInfo", infoIndex); err != nil {
+		return err
+	}
+
+	// Paths
+	ids := map[string]uint32{}
+	list := make([]TreeIndex, 0, len(paths))
+	sizes := []TreeItem{}
+	links := newHardLinks()
+	for i := range paths {
+		p := &paths[i]
+		id := uint32(i + 1)
+		name := path.Clean(p.Path)
+		if _, ok := ids[name]; ok {
+			return fmt.Errorf("duplicate path: %v", p.Path)
+		}
+		ids[name] = id
+
+		file := &File{Name: path.Base(name)}
+		if dir := path.Dir(name); name != dir {
+			parent, ok := ids[dir]
+			if !ok {
+				return fmt.Errorf("parent not found: %v", p.Path)
+			}
+			file.Parent = parent
+		}
+
+		if p.Size64 > math.MaxUint32 {
+			value := make([]byte, 8)
+			binary.BigEndian.PutUint64(value, p.Size64)
+			sizes = append(sizes, TreeItem{Key: idKey(id), Value: value})
+		}
+		links.add(p, id)
+
+		info1 := &PathInfo1{ID: id, Index: w.AddBlock(encodePathInfo2(p))}
+		buf := &bytes.Buffer{}
+		binary.Write(buf, binary.BigEndian, info1)
+		list = append(list, TreeIndex{
+			ValueIndex: w.AddBlock(buf.Bytes()),
+			KeyIndex:   w.AddBlock(encodeFile(file)),
+		})
+	}
+	w.BlockSize = DefaultBlockSize
+	index, err := w.addTree(list, 0)
+	if err != nil {
+		return err
+	}
+	if err := w.AddVar("Paths", index); err != nil {
+		return err
+	}
+
+	// HLIndex: hard link groups, by id of their first path
+	if _, err := w.WriteTree("HLIndex", links.items()); err != nil {
+		return err
+	}
+
+	// VIndex: points to an empty tree
+	w.BlockSize = 128
+	vtree, err := w.AddTree(nil)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, &VIndex{Unknown0: 1, IndexToVTree: vtree})
+	if _, err := w.WriteBlock("VIndex", buf.Bytes()); err != nil {
+		return err
+	}
+
+	// Size64: sizes of files larger than 4GB, ids are added in order
+	if _, err := w.WriteTree("Size64", sizes); err != nil {
+		return err
+	}
+
+	buf = &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint32(1))
+	binary.Write(buf, binary.BigEndian, uint32(len(paths)))
+	binary.Write(buf, binary.BigEndian, uint32(1))
+	binary.Write(buf, binary.BigEndian, &BomInfoEntry{})
+	w.blocks[infoIndex] = buf.Bytes()
+
+	_, err = w.WriteTo(out)
+	return err
+}
+
+// hardLinks: group files by st_dev and st_ino, or by HardLink of decoded paths
+type hardLinks struct {
+	groups map[[2]uint64][]uint32
+	order  [][2]uint64
+}
+
+func newHardLinks() *hardLinks {
+	return &hardLinks{groups: map[[2]uint64][]uint32{}}
+}
+
+func (h *hardLinks) add(p *Path, id uint32) {
+	if p.Type != PathTypeFile {
+		return
+	}
+	var k [2]uint64
+	switch {
+	case p.Inode != 0:
+		k = [2]uint64{p.Device, p.Inode}
+	case p.HardLink != 0:
+		// inode 0 is never used by file systems, so these keys do not collide with real ones
+		k = [2]uint64{uint64(p.HardLink), 0}
+	default:
+		return
+	}
+	if _, ok := h.groups[k]; !ok {
+		h.order = append(h.order, k)
+	}
+	h.groups[k] = append(h.groups[k], id)
+}
+
+// items: groups of more than one path, in order of their first id
+func (h *hardLinks) items() []TreeItem {
+	items := []TreeItem{}
+	for _, k := range h.order {
+		ids := h.groups[k]
+		if len(ids) < 2 {
+			continue
+		}
+		value := make([]byte, 4*len(ids))
+		for i, id := range ids {
+			binary.BigEndian.PutUint32(value[i*4:], id)
+		}
+		items = append(items, TreeItem{Key: idKey(ids[0]), Value: value})
+	}
+	return items
+}
+
+// idKey: key of trees keyed by path id
+func idKey(id uint32) []byte {
+	k := make([]byte, 4)
+	binary.BigEndian.PutUint32(k, id)
+	return k
+}
+
+// PathsFromDir: walk directory root, returns paths of WriteInstaller in lexical order
+// checksums of files and links are calculated with Cksum, files larger than 4GB have Size64
+func PathsFromDir(root string) ([]Path, error) {
+	paths := []Path{}
+	err := filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+
+		p := Path{
+			Path:    "./" + filepath.ToSlash(rel),
+			Mode:    unixMode(info.Mode()),
+			ModTime: info.ModTime(),
+		}
+		if rel == "." {
+			p.Path = "."
+		}
+		p.UID, p.GID, p.Dev = fileOwner(info)
+		p.Device, p.Inode = fileID(info)
+
+		m := info.Mode()
+		switch {
+		case m.IsRegular():
+			p.Type = PathTypeFile
+			f, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			sum, n, err := Cksum(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			p.Checksum = sum
+			p.Size = uint32(n)
+			if n > math.MaxUint32 {
+				p.Size64 = uint64(n)
+			}
+		case m.IsDir():
+			p.Type = PathTypeDir
+		case m&os.ModeSymlink != 0:
+			p.Type = PathTypeLink
+			target, err := os.Readlink(name)
+			if err != nil {
+				return err
+			}
+			p.LinkName = target
+			p.Size = uint32(len(target))
+			p.Checksum, _, _ = Cksum(strings.NewReader(target))
+		case m&os.ModeDevice != 0:
+			p.Type = PathTypeDev
+		default:
+			// sockets and pipes are not in BOM
+			return nil
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// ParseListing: parse output of lsbom without flags, for mkbom -i
+//
+//	path	mode	uid/gid
+//	path	mode	uid/gid	[mtime]	size	checksum	# file, mtime with lsbom -m
+//	path	mode	uid/gid	size	checksum	linkname
+//	path	mode	uid/gid	devtype
+func ParseListing(r io.Reader) ([]Path, error) {
+	paths := []Path{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		p, err := parseListingLine(s.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		paths = append(paths, *p)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func parseListingLine(line string) (*Path, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("too few fields: %q", line)
+	}
+
+	num := func(s string, base int) (uint32, error) {
+		v, err := strconv.ParseUint(s, base, 32)
+		return uint32(v), err
+	}
+
+	p := &Path{Path: fields[0], ModTime: time.Unix(0, 0)}
+	mode, err := num(fields[1], 8)
+	if err != nil || mode > math.MaxUint16 {
+		return nil, fmt.Errorf("bad mode: %q", fields[1])
+	}
+	p.Mode = uint16(mode)
+
+	owner := strings.SplitN(fields[2], "/", 2)
+	if len(owner) != 2 {
+		return nil, fmt.Errorf("bad uid/gid: %q", fields[2])
+	}
+	if p.UID, err = num(owner[0], 10); err != nil {
+		return nil, fmt.Errorf("bad uid: %q", owner[0])
+	}
+	if p.GID, err = num(owner[1], 10); err != nil {
+		return nil, fmt.Errorf("bad gid: %q", owner[1])
+	}
+
+	rest := fields[3:]
+	switch p.Mode & 0170000 {
+	case 0040000:
+		p.Type = PathTypeDir
+		return p, nil
+	case 0100000:
+		p.Type = PathTypeFile
+		if len(rest) == 3 {
+			mtime, err := num(rest[0], 10)
+			if err != nil {
+				return nil, fmt.Errorf("bad mtime: %q", rest[0])
+			}
+			p.ModTime = time.Unix(int64(mtime), 0)
+			rest = rest[1:]
+		}
+		if len(rest) != 2 {
+			return nil, fmt.Errorf("file needs size and checksum: %q", line)
+		}
+	case 0120000:
+		p.Type = PathTypeLink
+		if len(rest) != 3 {
+			return nil, fmt.Errorf("link needs size, checksum and link name: %q", line)
+		}
+		p.LinkName = rest[2]
+	case 0020000, 0060000:
+		p.Type = PathTypeDev
+		if len(rest) != 1 {
+			return nil, fmt.Errorf("device needs device number: %q", line)
+		}
+		if p.Dev, err = num(rest[0], 10); err != nil {
+			return nil, fmt.Errorf("bad device number: %q", rest[0])
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unsupported file type of mode: %q", fields[1])
+	}
+
+	size, err := strconv.ParseUint(rest[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad size: %q", rest[0])
+	}
+	p.Size = uint32(size)
+	if size > math.MaxUint32 {
+		p.Size64 = size
+	}
+	if p.Checksum, err = num(rest[1], 10); err != nil {
+		return nil, fmt.Errorf("bad checksum: %q", rest[1])
+	}
+	return p, nil
+}
+
+// unixMode: st_mode of os.FileMode
+func unixMode(m os.FileMode) uint16 {
+	mode := uint16(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 01000
+	}
+	switch {
+	case m.IsDir():
+		mode |= 0040000
+	case m&os.ModeSymlink != 0:
+		mode |= 0120000
+	case m&os.ModeCharDevice != 0:
+		mode |= 0020000
+	case m&os.ModeDevice != 0:
+		mode |= 0060000
+	case m&os.ModeNamedPipe != 0:
+		mode |= 0010000
+	case m&os.ModeSocket != 0:
+		mode |= 0140000
+	default:
+		mode |= 0100000
+	}
+	return mode
+}
@@ -0,0 +1,238 @@
+package bom
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCksum(t *testing.T) {
+	// same as output of cksum command
+	cases := map[string]uint32{
+		"":                           4294967295,
+		"hello":                      3287646509,
+		"123456789":                  930766865,
+		string(make([]byte, 100000)): 1260869142,
+	}
+	for s, want := range cases {
+		sum, n, err := Cksum(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum != want || n != int64(len(s)) {
+			t.Errorf("cksum of %d bytes: %v %v, want %v", len(s), sum, n, want)
+		}
+	}
+}
+
+func TestWriteInstallerFromDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkbom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "usr", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "usr", "bin", "hello"), []byte("hello"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("hello", filepath.Join(dir, "usr", "bin", "hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := PathsFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteInstaller(buf, paths); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(bytes.NewReader(buf.Bytes()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"BomInfo", "Paths", "HLIndex", "VIndex", "Size64"}
+	if strings.Join(names, ",") != strings.Join(b.BlockNames(), ",") {
+		t.Fatalf("names: %v", b.BlockNames())
+	}
+
+	decoded, err := b.Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		path     string
+		t        PathType
+		mode     uint16
+		size     uint32
+		checksum uint32
+		link     string
+	}{
+		{".", PathTypeDir, 040700, 0, 0, ""},
+		{"./usr", PathTypeDir, 040755, 0, 0, ""},
+		{"./usr/bin", PathTypeDir, 040755, 0, 0, ""},
+		{"./usr/bin/hello", PathTypeFile, 0100755, 5, 3287646509, ""},
+		{"./usr/bin/hi", PathTypeLink, 0120777, 5, 3287646509, "hello"},
+	}
+	if len(decoded) != len(want) {
+		t.Fatalf("paths: %+v", decoded)
+	}
+	for i, w := range want {
+		p := decoded[i]
+		// permission of temp dir and files depends on umask
+		if p.Path != w.path || p.Type != w.t || p.Mode&0170000 != w.mode&0170000 || p.Size != w.size || p.Checksum != w.checksum || p.LinkName != w.link {
+			t.Errorf("path %d: %+v", i, p)
+		}
+		if p.UID != uint32(os.Getuid()) || p.GID != uint32(os.Getgid()) {
+			t.Errorf("path %d owner: %v/%v", i, p.UID, p.GID)
+		}
+		if p.ModTime.Unix() != paths[i].ModTime.Unix() {
+			t.Errorf("path %d mtime: %v", i, p.ModTime)
+		}
+	}
+}
+
+func TestParseListing(t *testing.T) {
+	listing := "" +
+		".\t40755\t0/0\n" +
+		"./bin\t40755\t0/0\n" +
+		"./bin/ls\t100755\t0/80\t1600000000\t38704\t3374285806\n" +
+		"./bin/cat\t100755\t0/80\t100\t1\n" +
+		"./bin/sh\t120755\t0/0\t4\t1234\tbash\n" +
+		"\n" +
+		"./null\t20666\t0/0\t50331650\n"
+	paths, err := ParseListing(strings.NewReader(listing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteInstaller(buf, paths); err != nil {
+		t.Fatal(err)
+	}
+	b := New(bytes.NewReader(buf.Bytes()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := b.Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 6 {
+		t.Fatalf("paths: %+v", decoded)
+	}
+	ls := decoded[2]
+	if ls.Path != "./bin/ls" || ls.GID != 80 || ls.Size != 38704 || ls.Checksum != 3374285806 || !ls.ModTime.Equal(time.Unix(1600000000, 0)) || ls.Parent != 2 {
+		t.Fatalf("ls: %+v", ls)
+	}
+	if sh := decoded[4]; sh.LinkName != "bash" || sh.Size != 4 {
+		t.Fatalf("sh: %+v", sh)
+	}
+	if null := decoded[5]; null.Type != PathTypeDev || null.Dev != 50331650 || null.Parent != 1 {
+		t.Fatalf("null: %+v", null)
+	}
+
+	for _, bad := range []string{
+		"./a\t40755\n",
+		"./a\t100644\t0/0\t1\n",
+		"./a\t100644\t0/0\t1\t2\n",
+		".\t40755\t0/0\n./a\t100644\t0/0\t1\t2\n./a\t100644\t0/0\t1\t2\n",
+	} {
+		paths, err := ParseListing(strings.NewReader(bad))
+		if err == nil {
+			err = WriteInstaller(ioutil.Discard, paths)
+		}
+		if err == nil {
+			t.Errorf("want error: %q", bad)
+		}
+	}
+}
+
+func TestWriteInstallerSize64(t *testing.T) {
+	listing := "" +
+		".\t40755\t0/0\n" +
+		"./big\t100644\t0/0\t5000000000\t1\n" +
+		"./small\t100644\t0/0\t100\t2\n"
+	paths, err := ParseListing(strings.NewReader(listing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if big := paths[1]; big.Size != uint32(5000000000&0xffffffff) || big.Size64 != 5000000000 {
+		t.Fatalf("big: %+v", big)
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteInstaller(buf, paths); err != nil {
+		t.Fatal(err)
+	}
+	b := New(bytes.NewReader(buf.Bytes()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := b.Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if big := decoded[1]; big.Path != "./big" || big.Size64 != 5000000000 || big.FileSize() != 5000000000 {
+		t.Fatalf("big: %+v", big)
+	}
+	if small := decoded[2]; small.Size64 != 0 || small.FileSize() != 100 {
+		t.Fatalf("small: %+v", small)
+	}
+}
+
+func TestWriteInstallerHardLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkbom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a", "c"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(dir, "a"), filepath.Join(dir, "b")); err != nil {
+		t.Skipf("hard link: %v", err)
+	}
+
+	paths, err := PathsFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths[1].Inode == 0 {
+		t.Skip("no inode numbers on this system")
+	}
+
+	// paths of directory, then written again from decoded paths
+	for round := 0; round < 2; round++ {
+		buf := &bytes.Buffer{}
+		if err := WriteInstaller(buf, paths); err != nil {
+			t.Fatal(err)
+		}
+		b := New(bytes.NewReader(buf.Bytes()))
+		if err := b.Parse(); err != nil {
+			t.Fatal(err)
+		}
+		paths, err = b.Paths()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, p := range paths {
+			got = append(got, fmt.Sprintf("%s:%d", p.Path, p.HardLink))
+		}
+		// ids are 1 for ".", 2 for "./a"
+		if want := ".:0,./a:2,./b:2,./c:0"; strings.Join(got, ",") != want {
+			t.Fatalf("round %d: %v, want %v", round, got, want)
+		}
+	}
+}
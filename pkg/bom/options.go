@@ -0,0 +1,69 @@
+package bom
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
+
+// Options: limits checked while reading untrusted input, zero value means the default limit
+type Options struct {
+	// max number of block table pointers
+	MaxBlocks uint32
+	// max number of vars
+	MaxVars uint32
+	// max number of pages from tree root to leaf
+	MaxTreeDepth int
+	// max width * height of decoded image
+	MaxPixels int64
+	// max bytes of one decompressed rendition
+	MaxDecompressedBytes int64
+}
+
+// DefaultOptions: limits used by New and NewReaderAt
+var DefaultOptions = Options{
+	MaxBlocks:            1 << 20,
+	MaxVars:              1 << 16,
+	MaxTreeDepth:         32,
+	MaxPixels:            16384 * 16384,
+	MaxDecompressedBytes: 1 << 30,
+}
+
+// withDefaults: replace zero fields with DefaultOptions
+func (o Options) withDefaults() Options {
+	if o.MaxBlocks == 0 {
+		o.MaxBlocks = DefaultOptions.MaxBlocks
+	}
+	if o.MaxVars == 0 {
+		o.MaxVars = DefaultOptions.MaxVars
+	}
+	if o.MaxTreeDepth == 0 {
+		o.MaxTreeDepth = DefaultOptions.MaxTreeDepth
+	}
+	if o.MaxPixels == 0 {
+		o.MaxPixels = DefaultOptions.MaxPixels
+	}
+	if o.MaxDecompressedBytes == 0 {
+		o.MaxDecompressedBytes = DefaultOptions.MaxDecompressedBytes
+	}
+	return o
+}
+
+// LimitError: input exceeds one of Options, errors.Is(err, ErrLimitExceeded) is true
+type LimitError struct {
+	// name of limit, like "MaxBlocks"
+	Limit string
+	Value int64
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeded: %d > %d", e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
@@ -0,0 +1,89 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestOptionsLimits(t *testing.T) {
+	d := buildMultiPageTree(false)
+	r := bytes.NewReader(d)
+	size := int64(len(d))
+
+	if err := NewWithOptions(r, size, Options{MaxBlocks: 10}).Parse(); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("MaxBlocks: %v", err)
+	}
+	if err := NewWithOptions(r, size, Options{MaxVars: 1}).Parse(); err != nil {
+		t.Fatalf("MaxVars: %v", err)
+	}
+
+	b := NewWithOptions(r, size, Options{MaxTreeDepth: 1})
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	err := b.ReadTree("TREE", func(k io.Reader, d io.Reader) error { return nil })
+	le := &LimitError{}
+	if !errors.As(err, &le) || le.Limit != "MaxTreeDepth" {
+		t.Fatalf("ReadTree MaxTreeDepth: %v", err)
+	}
+	if _, err := b.Lookup("TREE", []byte("k1"), nil); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Lookup MaxTreeDepth: %v", err)
+	}
+	if report := b.Verify(); !report.Has(ProblemTreeBroken) {
+		t.Fatalf("Verify MaxTreeDepth: %v", report)
+	}
+
+	// depth 2 is enough for one branch page
+	b = NewWithOptions(r, size, Options{MaxTreeDepth: 2})
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Lookup("TREE", []byte("k1"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	d = buildTestBom([][]byte{[]byte("a"), []byte("b")}, []Var{{Index: 1, Name: "A"}, {Index: 2, Name: "B"}})
+	if err := NewWithOptions(bytes.NewReader(d), int64(len(d)), Options{MaxVars: 1}).Parse(); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("MaxVars: %v", err)
+	}
+}
+
+func TestParseHugeCounts(t *testing.T) {
+	d := buildTestBom([][]byte{[]byte("a")}, []Var{{Index: 1, Name: "A"}})
+
+	// block table count larger than file
+	indexOffset := binary.BigEndian.Uint32(d[16:])
+	c := append([]byte{}, d...)
+	binary.BigEndian.PutUint32(c[indexOffset:], 1<<19)
+	if err := New(bytes.NewReader(c)).Parse(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("block table: %v", err)
+	}
+
+	// free list count larger than file, Writer writes an empty free list
+	w := NewWriter()
+	if _, err := w.WriteBlock("A", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	c = buf.Bytes()
+	indexOffset = binary.BigEndian.Uint32(c[16:])
+	n := binary.BigEndian.Uint32(c[indexOffset:])
+	binary.BigEndian.PutUint32(c[indexOffset+4+n*8:], 1<<19)
+	if err := New(bytes.NewReader(c)).Parse(); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("free list: %v", err)
+	}
+
+	// vars count larger than file
+	varsOffset := binary.BigEndian.Uint32(d[24:])
+	c = append([]byte{}, d...)
+	binary.BigEndian.PutUint32(c[varsOffset:], 1<<15)
+	if err := New(bytes.NewReader(c)).Parse(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("vars: %v", err)
+	}
+}
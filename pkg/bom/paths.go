@@ -0,0 +1,439 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/iineva/bom/pkg/helper"
+)
+
+// Installer and receipt BOM files, see bomutils
+// var 'Paths' is a tree, key is BOMFile, value is BOMPathInfo1
+// var 'Size64' is a tree of files larger than 4GB, key is uint32 id, value is uint64 size
+// var 'HLIndex' is a tree of hard links, key is uint32 id of first path of a group, value is uint32 ids of every path of the group
+// bomutils writes both empty, the layout of their entries is a guess, the one written by WriteInstaller,
+// Paths ignores entries which do not fit it
+
+var (
+	ErrPathLoop = errors.New("path parent loop")
+)
+
+type PathType uint8
+
+const (
+	PathTypeFile = PathType(1)
+	PathTypeDir  = PathType(2)
+	PathTypeLink = PathType(3)
+	PathTypeDev  = PathType(4)
+)
+
+func (t PathType) String() string {
+	switch t {
+	case PathTypeFile:
+		return "file"
+	case PathTypeDir:
+		return "dir"
+	case PathTypeLink:
+		return "link"
+	case PathTypeDev:
+		return "dev"
+	default:
+		return "unknown"
+	}
+}
+
+// value of 'Paths' tree
+type PathInfo1 struct {
+	// uint32_t id;
+	ID uint32
+	// uint32_t index; // Pointer to BOMPathInfo2
+	Index uint32
+}
+
+type PathInfo2 struct {
+	// uint8_t type; // See types above
+	Type PathType
+	// uint8_t unknown0; // = 1?
+	Unknown0 uint8
+	// uint16_t architecture; // Not sure exactly what this means
+	Architecture uint16
+	// uint16_t mode;
+	Mode uint16
+	// uint32_t user;
+	User uint32
+	// uint32_t group;
+	Group uint32
+	// uint32_t modtime;
+	ModTime uint32
+	// uint32_t size;
+	Size uint32
+	// uint8_t unknown1; // = 1?
+	Unknown1 uint8
+	// union {
+	// 	uint32_t checksum;
+	// 	uint32_t devType;
+	// };
+	Checksum uint32
+	// uint32_t linkNameLength;
+	LinkNameLength uint32
+	// char linkName[];
+	// LinkName string
+}
+
+// per architecture info of fat executable files, follows linkName when present
+type PathArch struct {
+	// uint32_t cpuType;
+	CPUType uint32
+	// uint32_t cpuSubtype;
+	CPUSubtype uint32
+	// uint32_t size;
+	Size uint32
+	// uint32_t checksum;
+	Checksum uint32
+}
+
+// key of 'Paths' tree
+type File struct {
+	// uint32_t parent; // Parent BOMPathInfo1->id
+	Parent uint32
+	// char name[];
+	Name string
+}
+
+// var 'BomInfo'
+type BomInfo struct {
+	// uint32_t version;
+	Version uint32
+	// uint32_t numberOfPaths;
+	NumberOfPaths uint32
+	// uint32_t numberOfInfoEntries;
+	NumberOfInfoEntries uint32
+	// BOMInfoEntry entries[];
+	Entries []BomInfoEntry
+}
+
+type BomInfoEntry struct {
+	// uint32_t unknown0;
+	Unknown0 uint32
+	// uint32_t unknown1;
+	Unknown1 uint32
+	// uint32_t unknown2;
+	Unknown2 uint32
+	// uint32_t unknown3;
+	Unknown3 uint32
+}
+
+// var 'VIndex'
+type VIndex struct {
+	// uint32_t unknown0; // = 1
+	Unknown0 uint32
+	// uint32_t indexToVTree;
+	IndexToVTree uint32
+	// uint32_t unknown2; // = 0
+	Unknown2 uint32
+	// uint8_t unknown3; // = 0
+	Unknown3 uint8
+}
+
+// Path: one decoded entry of 'Paths' tree, like a line of lsbom
+type Path struct {
+	ID     uint32
+	Parent uint32
+	// full path rebuilt from parent ids, like "./usr/bin"
+	Path string
+
+	Type         PathType
+	Architecture uint16
+	Mode         uint16 // st_mode, with file type bits
+	UID          uint32
+	GID          uint32
+	ModTime      time.Time
+	Size         uint32 // low 32 bits of size for files larger than 4GB
+	Size64       uint64 // size of files larger than 4GB, 0 for others
+	Checksum     uint32 // BSD cksum CRC32 of file content
+	LinkName     string // target of link
+	Dev          uint32 // device number of dev
+	Archs        []PathArch
+
+	// st_dev and st_ino of files, set by PathsFromDir, files with the same pair are hard links
+	Device uint64
+	Inode  uint64
+	// id of first path of hard link group in 'HLIndex', 0 if not a hard link
+	HardLink uint32
+}
+
+// FileSize: size of file, Size64 if set
+func (p *Path) FileSize() uint64 {
+	if p.Size64 != 0 {
+		return p.Size64
+	}
+	return uint64(p.Size)
+}
+
+// Name: last element of path
+func (p *Path) Name() string {
+	if i := strings.LastIndexByte(p.Path, '/'); i >= 0 {
+		return p.Path[i+1:]
+	}
+	return p.Path
+}
+
+// Paths: decode 'Paths' tree of installer BOM, in tree order
+func (b *bom) Paths() ([]Path, error) {
+	paths := []Path{}
+	files := map[uint32]File{}
+	if err := b.ReadTree("Paths", func(k io.Reader, d io.Reader) error {
+		file, err := decodeFile(k)
+		if err != nil {
+			return err
+		}
+
+		v, err := ioutil.ReadAll(d)
+		if err != nil {
+			return err
+		}
+		c := helper.NewCursor(v, binary.BigEndian)
+		info1 := PathInfo1{ID: c.Uint32(), Index: c.Uint32()}
+		if err := c.Err(); err != nil {
+			return err
+		}
+		r, err := b.blockReader(info1.Index)
+		if err != nil {
+			return err
+		}
+		p, err := decodePathInfo2(r)
+		if err != nil {
+			return err
+		}
+
+		p.ID = info1.ID
+		p.Parent = file.Parent
+		files[info1.ID] = *file
+		paths = append(paths, *p)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sizes, err := b.idTree("Size64")
+	if err != nil {
+		return nil, err
+	}
+	links, err := b.idTree("HLIndex")
+	if err != nil {
+		return nil, err
+	}
+	groups := map[uint32]uint32{}
+	for first, d := range links {
+		if len(d)%4 != 0 {
+			continue
+		}
+		for i := 0; i < len(d); i += 4 {
+			groups[binary.BigEndian.Uint32(d[i:])] = first
+		}
+	}
+
+	// rebuild full paths, parents may be listed after children
+	for i := range paths {
+		p := &paths[i]
+		full, err := fullPath(files, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		p.Path = full
+		if d, ok := sizes[p.ID]; ok && len(d) == 8 {
+			p.Size64 = binary.BigEndian.Uint64(d)
+		}
+		p.HardLink = groups[p.ID]
+	}
+	return paths, nil
+}
+
+// idTree: values of tree keyed by uint32 path id, like 'Size64', empty if there is no such var,
+// keys which are not 4 bytes are ignored
+func (b *bom) idTree(name string) (map[uint32][]byte, error) {
+	values := map[uint32][]byte{}
+	err := b.ReadTree(name, func(k io.Reader, d io.Reader) error {
+		key, err := ioutil.ReadAll(k)
+		if err != nil {
+			return err
+		}
+		if len(key) != 4 {
+			return nil
+		}
+		v, err := ioutil.ReadAll(d)
+		if err != nil {
+			return err
+		}
+		values[binary.BigEndian.Uint32(key)] = v
+		return nil
+	})
+	if errors.Is(err, ErrNameNotMatch) {
+		return values, nil
+	}
+	return values, err
+}
+
+func fullPath(files map[uint32]File, id uint32) (string, error) {
+	names := []string{}
+	for n := 0; id != 0; n++ {
+		// parent chain can not be longer than number of files
+		if n > len(files) {
+			return "", ErrPathLoop
+		}
+		f, ok := files[id]
+		if !ok {
+			break
+		}
+		names = append(names, f.Name)
+		id = f.Parent
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return strings.Join(names, "/"), nil
+}
+
+func decodeFile(r io.Reader) (*File, error) {
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c := helper.NewCursor(d, binary.BigEndian)
+	f := &File{Parent: c.Uint32()}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	f.Name = cString(c.Bytes(c.Len()))
+	return f, nil
+}
+
+func decodePathInfo2(r io.Reader) (*Path, error) {
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c := helper.NewCursor(d, binary.BigEndian)
+	info := PathInfo2{
+		Type:           PathType(c.Uint8()),
+		Unknown0:       c.Uint8(),
+		Architecture:   c.Uint16(),
+		Mode:           c.Uint16(),
+		User:           c.Uint32(),
+		Group:          c.Uint32(),
+		ModTime:        c.Uint32(),
+		Size:           c.Uint32(),
+		Unknown1:       c.Uint8(),
+		Checksum:       c.Uint32(),
+		LinkNameLength: c.Uint32(),
+	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	p := &Path{
+		Type:         info.Type,
+		Architecture: info.Architecture,
+		Mode:         info.Mode,
+		UID:          info.User,
+		GID:          info.Group,
+		ModTime:      time.Unix(int64(info.ModTime), 0).UTC(),
+		Size:         info.Size,
+	}
+	if info.Type == PathTypeDev {
+		p.Dev = info.Checksum
+	} else {
+		p.Checksum = info.Checksum
+	}
+
+	if uint32(c.Len()) < info.LinkNameLength {
+		return nil, io.ErrUnexpectedEOF
+	}
+	p.LinkName = cString(c.Bytes(int(info.LinkNameLength)))
+
+	// executable files: uint32_t count, PathArch archs[count]
+	// not documented by bomutils, decode only when the size matches
+	if c.Len() >= 4 {
+		n := c.Uint32()
+		if uint64(c.Len()) == uint64(n)*16 {
+			p.Archs = make([]PathArch, n)
+			for i := range p.Archs {
+				p.Archs[i] = PathArch{CPUType: c.Uint32(), CPUSubtype: c.Uint32(), Size: c.Uint32(), Checksum: c.Uint32()}
+			}
+		}
+	}
+
+	return p, nil
+}
+
+func encodeFile(f *File) []byte {
+	d := make([]byte, 4+len(f.Name)+1)
+	binary.BigEndian.PutUint32(d, f.Parent)
+	copy(d[4:], f.Name)
+	return d
+}
+
+func encodePathInfo2(p *Path) []byte {
+	info := PathInfo2{
+		Type:         p.Type,
+		Unknown0:     1,
+		Architecture: p.Architecture,
+		Mode:         p.Mode,
+		User:         p.UID,
+		Group:        p.GID,
+		ModTime:      uint32(p.ModTime.Unix()),
+		Size:         p.Size,
+		Unknown1:     1,
+		Checksum:     p.Checksum,
+	}
+	if p.Type == PathTypeDev {
+		info.Checksum = p.Dev
+	}
+	if p.Type == PathTypeLink {
+		info.LinkNameLength = uint32(len(p.LinkName) + 1)
+	}
+
+	d := make([]byte, pathInfo2Size, pathInfo2Size+int(info.LinkNameLength)+4+len(p.Archs)*16)
+	d[0] = uint8(info.Type)
+	d[1] = info.Unknown0
+	binary.BigEndian.PutUint16(d[2:], info.Architecture)
+	binary.BigEndian.PutUint16(d[4:], info.Mode)
+	binary.BigEndian.PutUint32(d[6:], info.User)
+	binary.BigEndian.PutUint32(d[10:], info.Group)
+	binary.BigEndian.PutUint32(d[14:], info.ModTime)
+	binary.BigEndian.PutUint32(d[18:], info.Size)
+	d[22] = info.Unknown1
+	binary.BigEndian.PutUint32(d[23:], info.Checksum)
+	binary.BigEndian.PutUint32(d[27:], info.LinkNameLength)
+	if p.Type == PathTypeLink {
+		d = append(d, p.LinkName...)
+		d = append(d, 0)
+	}
+	if len(p.Archs) > 0 {
+		d = appendUint32(d, uint32(len(p.Archs)))
+		for _, a := range p.Archs {
+			d = appendUint32(d, a.CPUType)
+			d = appendUint32(d, a.CPUSubtype)
+			d = appendUint32(d, a.Size)
+			d = appendUint32(d, a.Checksum)
+		}
+	}
+	return d
+}
+
+// appendUint32: append big endian v to d
+func appendUint32(d []byte, v uint32) []byte {
+	return append(d, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// cString: bytes before first NUL
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
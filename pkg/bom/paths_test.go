@@ -0,0 +1,155 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testPath struct {
+	id, parent uint32
+	name       string
+	info       PathInfo2
+	link       string
+	archs      []PathArch
+}
+
+func buildTestPathsBom(paths []testPath) []byte {
+	w := NewWriter()
+	writeTestPaths(w, paths)
+	buf := &bytes.Buffer{}
+	w.WriteTo(buf)
+	return buf.Bytes()
+}
+
+// writeTestPaths: add 'Paths' tree of paths to w
+func writeTestPaths(w *Writer, paths []testPath) {
+	list := []TreeIndex{}
+	for _, p := range paths {
+		buf := &bytes.Buffer{}
+		info := p.info
+		if p.link != "" {
+			info.LinkNameLength = uint32(len(p.link) + 1)
+		}
+		binary.Write(buf, binary.BigEndian, info)
+		if p.link != "" {
+			buf.WriteString(p.link)
+			buf.WriteByte(0)
+		}
+		if len(p.archs) > 0 {
+			binary.Write(buf, binary.BigEndian, uint32(len(p.archs)))
+			binary.Write(buf, binary.BigEndian, p.archs)
+		}
+		info2 := w.AddBlock(buf.Bytes())
+
+		buf = &bytes.Buffer{}
+		binary.Write(buf, binary.BigEndian, PathInfo1{ID: p.id, Index: info2})
+		value := w.AddBlock(buf.Bytes())
+
+		buf = &bytes.Buffer{}
+		binary.Write(buf, binary.BigEndian, p.parent)
+		buf.WriteString(p.name)
+		buf.WriteByte(0)
+		key := w.AddBlock(buf.Bytes())
+
+		list = append(list, TreeIndex{ValueIndex: value, KeyIndex: key})
+	}
+	index, _ := w.addTree(list, 0)
+	w.AddVar("Paths", index)
+}
+
+func TestPaths(t *testing.T) {
+	mtime := uint32(1600000000)
+	archs := []PathArch{{CPUType: 7, CPUSubtype: 3, Size: 100, Checksum: 1}, {CPUType: 0x01000007, CPUSubtype: 3, Size: 200, Checksum: 2}}
+	d := buildTestPathsBom([]testPath{
+		{id: 1, parent: 0, name: ".", info: PathInfo2{Type: PathTypeDir, Mode: 040755, ModTime: mtime}},
+		// child listed before parent
+		{id: 3, parent: 2, name: "ls", info: PathInfo2{Type: PathTypeFile, Mode: 0100755, User: 0, Group: 80, ModTime: mtime, Size: 300, Checksum: 0x12345678}, archs: archs},
+		{id: 2, parent: 1, name: "bin", info: PathInfo2{Type: PathTypeDir, Mode: 040755}},
+		{id: 4, parent: 2, name: "sh", info: PathInfo2{Type: PathTypeLink, Mode: 0120755, Size: 4, Checksum: 0xabcdef}, link: "bash"},
+		{id: 5, parent: 1, name: "null", info: PathInfo2{Type: PathTypeDev, Mode: 020666, Checksum: 0x03000002}},
+	})
+
+	b := New(bytes.NewReader(d))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := b.Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := time.Unix(int64(mtime), 0).UTC()
+	tpaths := []Path{
+		{ID: 1, Parent: 0, Path: ".", Type: PathTypeDir, Mode: 040755, ModTime: tm},
+		{ID: 3, Parent: 2, Path: "./bin/ls", Type: PathTypeFile, Mode: 0100755, GID: 80, ModTime: tm, Size: 300, Checksum: 0x12345678, Archs: archs},
+		{ID: 2, Parent: 1, Path: "./bin", Type: PathTypeDir, Mode: 040755, ModTime: time.Unix(0, 0).UTC()},
+		{ID: 4, Parent: 2, Path: "./bin/sh", Type: PathTypeLink, Mode: 0120755, ModTime: time.Unix(0, 0).UTC(), Size: 4, Checksum: 0xabcdef, LinkName: "bash"},
+		{ID: 5, Parent: 1, Path: "./null", Type: PathTypeDev, Mode: 020666, ModTime: time.Unix(0, 0).UTC(), Dev: 0x03000002},
+	}
+	if !reflect.DeepEqual(tpaths, paths) {
+		t.Fatalf("paths: %+v", paths)
+	}
+	if paths[1].Name() != "ls" {
+		t.Fatalf("name: %v", paths[1].Name())
+	}
+}
+
+func TestPathsLoop(t *testing.T) {
+	d := buildTestPathsBom([]testPath{
+		{id: 1, parent: 2, name: "a", info: PathInfo2{Type: PathTypeDir}},
+		{id: 2, parent: 1, name: "b", info: PathInfo2{Type: PathTypeDir}},
+	})
+	b := New(bytes.NewReader(d))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Paths(); err != ErrPathLoop {
+		t.Fatalf("want ErrPathLoop, got: %v", err)
+	}
+}
+
+// layout of 'Size64' and 'HLIndex' is a guess, entries which do not fit it are ignored
+func TestPathsMalformedSize64(t *testing.T) {
+	w := NewWriter()
+	writeTestPaths(w, []testPath{
+		{id: 1, parent: 0, name: ".", info: PathInfo2{Type: PathTypeDir}},
+		{id: 2, parent: 1, name: "a", info: PathInfo2{Type: PathTypeFile, Size: 1}},
+		{id: 3, parent: 1, name: "b", info: PathInfo2{Type: PathTypeFile, Size: 2}},
+	})
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, 5000000000)
+	if _, err := w.WriteTree("Size64", []TreeItem{
+		{Key: []byte{0, 0, 0, 2}, Value: []byte{0, 0, 0, 1}},
+		{Key: []byte{0, 0, 0, 3}, Value: size},
+		{Key: []byte{0, 0, 0, 3, 0}, Value: size},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteTree("HLIndex", []TreeItem{
+		{Key: []byte{0, 0, 0, 2}, Value: []byte{0, 0, 0, 2, 0, 0}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(bytes.NewReader(buf.Bytes()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := b.Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := paths[1]; a.Size64 != 0 || a.FileSize() != 1 || a.HardLink != 0 {
+		t.Fatalf("a: %+v", a)
+	}
+	if b := paths[2]; b.Size64 != 5000000000 {
+		t.Fatalf("b: %+v", b)
+	}
+}
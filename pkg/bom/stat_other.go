@@ -0,0 +1,18 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package bom
+
+import (
+	"os"
+)
+
+// fileOwner: files are owned by root on systems without unix owners
+func fileOwner(info os.FileInfo) (uid, gid, dev uint32) {
+	return 0, 0, 0
+}
+
+// fileID: hard links are not detected on these systems
+func fileID(info os.FileInfo) (dev, ino uint64) {
+	return 0, 0
+}
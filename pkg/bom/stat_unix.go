@@ -0,0 +1,25 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package bom
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner: uid, gid and device number of file
+func fileOwner(info os.FileInfo) (uid, gid, dev uint32) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Uid, st.Gid, uint32(st.Rdev)
+	}
+	return 0, 0, 0
+}
+
+// fileID: st_dev and st_ino of file, files with the same pair are hard links
+func fileID(info os.FileInfo) (dev, ino uint64) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), uint64(st.Ino)
+	}
+	return 0, 0
+}
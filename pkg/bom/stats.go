@@ -0,0 +1,87 @@
+package bom
+
+import (
+	"sort"
+)
+
+// Stats: block allocation of file
+type Stats struct {
+	FileSize int64
+	// non-null entries of block table
+	Blocks int
+	// bytes of non-null blocks
+	UsedBytes int64
+	// non-empty entries of free list
+	FreeExtents int
+	// bytes of free list entries
+	FreeBytes int64
+	// length of the largest free list entry
+	LargestFreeExtent int64
+	// 1 - LargestFreeExtent / FreeBytes, 0 when free space is one extent or none
+	Fragmentation float64
+	// bytes not in header, block table, vars or any block, includes free space
+	SlackBytes int64
+}
+
+// FreeList: free list after block table, Parse must be called first
+func (b *bom) FreeList() []Pointer {
+	if b.freeList == nil {
+		return nil
+	}
+	list := make([]Pointer, len(b.freeList.FreeListPointers))
+	copy(list, b.freeList.FreeListPointers)
+	return list
+}
+
+// Stats: block allocation statistics, Parse must be called first
+func (b *bom) Stats() *Stats {
+	s := &Stats{FileSize: b.size}
+	h := b.header
+	extents := []extent{
+		{start: 0, end: headerSize},
+		{start: uint64(h.IndexOffset), end: uint64(h.IndexOffset) + uint64(h.IndexLength)},
+		{start: uint64(h.VarsOffset), end: uint64(h.VarsOffset) + uint64(h.VarsLength)},
+	}
+	for i, p := range b.blockTable.BlockPointers {
+		if i == 0 || (p.Address == 0 && p.Length == 0) {
+			continue
+		}
+		s.Blocks++
+		s.UsedBytes += int64(p.Length)
+		extents = append(extents, extent{start: uint64(p.Address), end: uint64(p.Address) + uint64(p.Length)})
+	}
+	for _, p := range b.FreeList() {
+		if p.Length == 0 {
+			continue
+		}
+		s.FreeExtents++
+		s.FreeBytes += int64(p.Length)
+		if int64(p.Length) > s.LargestFreeExtent {
+			s.LargestFreeExtent = int64(p.Length)
+		}
+	}
+	if s.FreeBytes > 0 {
+		s.Fragmentation = 1 - float64(s.LargestFreeExtent)/float64(s.FreeBytes)
+	}
+
+	// bytes of file covered by the union of extents
+	sort.Slice(extents, func(i, j int) bool {
+		return extents[i].start < extents[j].start
+	})
+	size := uint64(b.size)
+	covered, end := uint64(0), uint64(0)
+	for _, e := range extents {
+		if e.end > size {
+			e.end = size
+		}
+		if e.start < end {
+			e.start = end
+		}
+		if e.end > e.start {
+			covered += e.end - e.start
+			end = e.end
+		}
+	}
+	s.SlackBytes = int64(size - covered)
+	return s
+}
@@ -0,0 +1,415 @@
+package bom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+type ProblemKind int
+
+const (
+	// block pointer is outside of file
+	ProblemBlockOutOfFile = ProblemKind(iota + 1)
+	// two blocks, or a block and header, block table or vars, share bytes
+	ProblemBlockOverlap
+	// var points to the null entry
+	ProblemVarNullBlock
+	// var points to an index not in block table
+	ProblemVarMissingBlock
+	// header NumberOfBlocks is not the number of non-null entries in block table
+	ProblemNumberOfBlocks
+	// tree PathCount is not the number of entries in all leaves
+	ProblemTreePathCount
+	// tree page, key or value block is missing or broken
+	ProblemTreeBroken
+	// non-null block not referenced by any var or tree
+	ProblemOrphanBlock
+	// installer BOM: parent ids of Paths form a loop, Paths would fail with ErrPathLoop
+	ProblemPathLoop
+	// Forward or Backward of a leaf page is not the next or previous leaf in tree order
+	ProblemTreeLinks
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case ProblemBlockOutOfFile:
+		return "block out of file"
+	case ProblemBlockOverlap:
+		return "block overlap"
+	case ProblemVarNullBlock:
+		return "var null block"
+	case ProblemVarMissingBlock:
+		return "var missing block"
+	case ProblemNumberOfBlocks:
+		return "number of blocks"
+	case ProblemTreePathCount:
+		return "tree path count"
+	case ProblemTreeBroken:
+		return "tree broken"
+	case ProblemOrphanBlock:
+		return "orphan block"
+	case ProblemPathLoop:
+		return "path parent loop"
+	case ProblemTreeLinks:
+		return "tree links"
+	default:
+		return fmt.Sprintf("unknown problem %d", int(k))
+	}
+}
+
+// Problem: one problem found by Verify
+type Problem struct {
+	Kind    ProblemKind
+	Block   uint32 // block table index, 0 if not about a block
+	Var     string // var name, empty if not about a var
+	Message string
+}
+
+func (p Problem) String() string {
+	s := p.Kind.String()
+	if p.Var != "" {
+		s += fmt.Sprintf(" var '%s'", p.Var)
+	}
+	if p.Block != 0 {
+		s += fmt.Sprintf(" block %d", p.Block)
+	}
+	if p.Message != "" {
+		s += ": " + p.Message
+	}
+	return s
+}
+
+// Report: result of Verify
+type Report struct {
+	Problems []Problem
+}
+
+// OK: no problem found
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Has: report contains problem of kind
+func (r *Report) Has(kind ProblemKind) bool {
+	for _, p := range r.Problems {
+		if p.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) add(kind ProblemKind, block uint32, name string, format string, a ...interface{}) {
+	r.Problems = append(r.Problems, Problem{Kind: kind, Block: block, Var: name, Message: fmt.Sprintf(format, a...)})
+}
+
+// verifier: state of one Verify call
+type verifier struct {
+	b      *bom
+	report *Report
+	// referenced blocks
+	used map[uint32]bool
+	// installer BOM: parent of each path id in Paths
+	parents map[uint32]uint32
+}
+
+// Verify: check structure of file, Parse must be called first
+// blocks which can not be read are reported as problems
+func (b *bom) Verify() *Report {
+	v := &verifier{b: b, report: &Report{}, used: map[uint32]bool{}, parents: map[uint32]uint32{}}
+	v.blocks(uint64(b.size))
+	for _, va := range b.vars {
+		v.variable(va)
+	}
+	v.orphans()
+	v.pathLoops()
+	return v.report
+}
+
+type extent struct {
+	start, end uint64
+	block      uint32
+	name       string
+}
+
+// blocks: check block pointers against file size, each other and header
+func (v *verifier) blocks(size uint64) {
+	h := v.b.header
+	extents := []extent{
+		{start: 0, end: headerSize, name: "header"},
+		{start: uint64(h.IndexOffset), end: uint64(h.IndexOffset) + uint64(h.IndexLength), name: "block table"},
+		{start: uint64(h.VarsOffset), end: uint64(h.VarsOffset) + uint64(h.VarsLength), name: "vars"},
+	}
+	for _, e := range extents {
+		if e.end > size {
+			v.report.add(ProblemBlockOutOfFile, 0, "", "%s %d-%d, file size %d", e.name, e.start, e.end, size)
+		}
+	}
+
+	n := uint32(0)
+	for i, p := range v.b.blockTable.BlockPointers {
+		if i == 0 || (p.Address == 0 && p.Length == 0) {
+			continue
+		}
+		n++
+		e := extent{start: uint64(p.Address), end: uint64(p.Address) + uint64(p.Length), block: uint32(i)}
+		if e.end > size {
+			v.report.add(ProblemBlockOutOfFile, e.block, "", "address %d length %d, file size %d", p.Address, p.Length, size)
+		}
+		if p.Length > 0 {
+			extents = append(extents, e)
+		}
+	}
+	if n != h.NumberOfBlocks {
+		v.report.add(ProblemNumberOfBlocks, 0, "", "header %d, block table %d", h.NumberOfBlocks, n)
+	}
+
+	sort.SliceStable(extents, func(i, j int) bool {
+		return extents[i].start < extents[j].start
+	})
+	// sweep by address, compare with the extent reaching furthest so far
+	last := 0
+	for i := 1; i < len(extents); i++ {
+		a, e := extents[last], extents[i]
+		if a.end > e.start {
+			if e.block == 0 {
+				e, a = a, e
+			}
+			other := a.name
+			if a.block != 0 {
+				other = fmt.Sprintf("block %d", a.block)
+			}
+			v.report.add(ProblemBlockOverlap, e.block, "", "overlaps %s", other)
+		}
+		if extents[i].end > extents[last].end {
+			last = i
+		}
+	}
+}
+
+// variable: check var and everything referenced by it
+func (v *verifier) variable(va Var) {
+	if va.Index == 0 {
+		v.report.add(ProblemVarNullBlock, 0, va.Name, "")
+		return
+	}
+	if va.Index >= uint32(len(v.b.blockTable.BlockPointers)) {
+		v.report.add(ProblemVarMissingBlock, va.Index, va.Name, "block table has %d entries", len(v.b.blockTable.BlockPointers))
+		return
+	}
+	p := v.b.blockTable.BlockPointers[va.Index]
+	if p.Address == 0 && p.Length == 0 {
+		v.report.add(ProblemVarNullBlock, va.Index, va.Name, "")
+		return
+	}
+	v.used[va.Index] = true
+
+	entry, ok := v.treeEntry(va.Index)
+	if ok {
+		v.tree(va.Name, va.Index, entry)
+		return
+	}
+
+	// installer BOM: VIndex points to a tree
+	if va.Name == "VIndex" {
+		d, err := v.read(va.Index)
+		if err != nil || len(d) < 8 {
+			return
+		}
+		index := binary.BigEndian.Uint32(d[4:])
+		if entry, ok := v.treeEntry(index); ok {
+			v.used[index] = true
+			v.tree(va.Name, index, entry)
+		} else {
+			v.report.add(ProblemTreeBroken, index, va.Name, "VIndex tree not found")
+		}
+	}
+}
+
+// treeEntry: decode block as tree entry if it looks like one
+func (v *verifier) treeEntry(index uint32) (*TreeEntry, bool) {
+	// some writers pad tree entry, Assets.car has 29 bytes
+	if !v.exists(index) || v.b.blockTable.BlockPointers[index].Length < treeEntrySize {
+		return nil, false
+	}
+	d, err := v.b.blockData(index)
+	if err != nil {
+		return nil, false
+	}
+	entry, err := decodeTreeEntry(d)
+	if err != nil || entry.Tag.String() != "tree" {
+		return nil, false
+	}
+	return entry, true
+}
+
+// tree: walk every page of tree from root, count leaf entries and check links of leaf pages
+func (v *verifier) tree(name string, index uint32, entry *TreeEntry) {
+	count := uint32(0)
+	visited := map[uint32]bool{}
+	// leaf pages in tree order
+	leaves := []uint32{}
+	links := map[uint32]*Tree{}
+	var walk func(page uint32, depth int) bool
+	walk = func(page uint32, depth int) bool {
+		if depth > v.b.opts.MaxTreeDepth {
+			v.report.add(ProblemTreeBroken, page, name, "deeper than MaxTreeDepth %d", v.b.opts.MaxTreeDepth)
+			return false
+		}
+		if visited[page] {
+			v.report.add(ProblemTreeBroken, page, name, "page visited twice")
+			return false
+		}
+		visited[page] = true
+		if !v.exists(page) {
+			v.report.add(ProblemTreeBroken, page, name, "page block missing")
+			return false
+		}
+		v.used[page] = true
+
+		tree, err := v.b.readPage(page)
+		if err != nil {
+			v.report.add(ProblemTreeBroken, page, name, "read page: %v", err)
+			return false
+		}
+		if tree.IsLeaf != 0 {
+			leaves = append(leaves, page)
+			links[page] = tree
+		}
+		for _, pi := range tree.List {
+			if tree.IsLeaf == 0 {
+				if !walk(pi.ValueIndex, depth+1) {
+					return false
+				}
+				if !entry.InlineKeys() && v.exists(pi.KeyIndex) {
+					v.used[pi.KeyIndex] = true
+				}
+				continue
+			}
+			count++
+			if !entry.InlineKeys() {
+				if !v.exists(pi.KeyIndex) {
+					v.report.add(ProblemTreeBroken, pi.KeyIndex, name, "key block missing in page %d", page)
+					continue
+				}
+				v.used[pi.KeyIndex] = true
+			}
+			if !v.exists(pi.ValueIndex) {
+				v.report.add(ProblemTreeBroken, pi.ValueIndex, name, "value block missing in page %d", page)
+				continue
+			}
+			v.used[pi.ValueIndex] = true
+			if name == "Paths" {
+				v.pathInfo(name, pi.KeyIndex, pi.ValueIndex)
+			}
+		}
+		return true
+	}
+	if !walk(entry.Index, 1) {
+		return
+	}
+	if count != entry.PathCount {
+		v.report.add(ProblemTreePathCount, index, name, "PathCount %d, leaves %d", entry.PathCount, count)
+	}
+	for i, page := range leaves {
+		prev, next := uint32(0), uint32(0)
+		if i > 0 {
+			prev = leaves[i-1]
+		}
+		if i < len(leaves)-1 {
+			next = leaves[i+1]
+		}
+		if tree := links[page]; tree.Forward != next || tree.Backward != prev {
+			v.report.add(ProblemTreeLinks, page, name, "Forward %d Backward %d, want %d %d", tree.Forward, tree.Backward, next, prev)
+		}
+	}
+}
+
+// pathInfo: installer BOM, value of Paths points to BOMPathInfo2, key starts with parent id
+func (v *verifier) pathInfo(name string, key, index uint32) {
+	d, err := v.read(index)
+	if err != nil || len(d) < 8 {
+		v.report.add(ProblemTreeBroken, index, name, "bad BOMPathInfo1")
+		return
+	}
+	if k, err := v.read(key); err == nil && len(k) >= 4 {
+		v.parents[binary.BigEndian.Uint32(d)] = binary.BigEndian.Uint32(k)
+	}
+	info := binary.BigEndian.Uint32(d[4:])
+	if !v.exists(info) {
+		v.report.add(ProblemTreeBroken, info, name, "BOMPathInfo2 block missing")
+		return
+	}
+	v.used[info] = true
+}
+
+// pathLoops: installer BOM, every parent chain of Paths must end at id 0 or an id not in tree
+// each loop is reported once, with its ids in chain order
+func (v *verifier) pathLoops() {
+	ids := make([]uint32, 0, len(v.parents))
+	for id := range v.parents {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// 1: on current chain, 2: chain checked
+	state := map[uint32]int{}
+	for _, id := range ids {
+		chain := []uint32{}
+		for id != 0 && state[id] == 0 {
+			parent, ok := v.parents[id]
+			if !ok {
+				break
+			}
+			state[id] = 1
+			chain = append(chain, id)
+			id = parent
+		}
+		if state[id] == 1 {
+			// id is on current chain, the loop is from id to the end of chain
+			loop := []string{}
+			for i := len(chain) - 1; i >= 0; i-- {
+				loop = append([]string{fmt.Sprint(chain[i])}, loop...)
+				if chain[i] == id {
+					break
+				}
+			}
+			v.report.add(ProblemPathLoop, 0, "Paths", "ids %s -> %d", strings.Join(loop, " -> "), id)
+		}
+		for _, c := range chain {
+			state[c] = 2
+		}
+	}
+}
+
+// orphans: non-null blocks never referenced
+func (v *verifier) orphans() {
+	for i, p := range v.b.blockTable.BlockPointers {
+		if i == 0 || (p.Address == 0 && p.Length == 0) {
+			continue
+		}
+		if !v.used[uint32(i)] {
+			v.report.add(ProblemOrphanBlock, uint32(i), "", "address %d length %d", p.Address, p.Length)
+		}
+	}
+}
+
+// exists: index is a non-null entry of block table
+func (v *verifier) exists(index uint32) bool {
+	if index == 0 || index >= uint32(len(v.b.blockTable.BlockPointers)) {
+		return false
+	}
+	p := v.b.blockTable.BlockPointers[index]
+	return p.Address != 0 || p.Length != 0
+}
+
+func (v *verifier) read(index uint32) ([]byte, error) {
+	r, err := v.b.blockReader(index)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
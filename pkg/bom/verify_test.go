@@ -0,0 +1,170 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func verifyBytes(t *testing.T, d []byte) *Report {
+	b := New(bytes.NewReader(d))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Verify()
+}
+
+func TestVerify(t *testing.T) {
+	f, err := openTestData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := New(f)
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	r := b.Verify()
+	if !r.OK() {
+		t.Fatalf("Assets.car: %v", r.Problems)
+	}
+
+	if r := verifyBytes(t, buildMultiPageTree(false)); !r.OK() {
+		t.Fatalf("multi page tree: %v", r.Problems)
+	}
+	if r := verifyBytes(t, buildTestPathsBom([]testPath{{id: 1, name: ".", info: PathInfo2{Type: PathTypeDir}}})); !r.OK() {
+		t.Fatalf("paths: %v", r.Problems)
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteInstaller(buf, []Path{{Path: ".", Type: PathTypeDir}}); err != nil {
+		t.Fatal(err)
+	}
+	if r := verifyBytes(t, buf.Bytes()); !r.OK() {
+		t.Fatalf("installer: %v", r.Problems)
+	}
+}
+
+func TestVerifyProblems(t *testing.T) {
+	// block table entry i of file written by buildTestBom
+	pointer := func(d []byte, i int) []byte {
+		offset := binary.BigEndian.Uint32(d[16:])
+		return d[offset+4+uint32(i)*8:]
+	}
+
+	cases := map[ProblemKind]func() []byte{
+		ProblemOrphanBlock: func() []byte {
+			w := NewWriter()
+			w.WriteBlock("A", []byte("a"))
+			w.AddBlock([]byte("orphan"))
+			buf := &bytes.Buffer{}
+			w.WriteTo(buf)
+			return buf.Bytes()
+		},
+		ProblemNumberOfBlocks: func() []byte {
+			d := buildTestBom([][]byte{[]byte("a")}, []Var{{Index: 1, Name: "A"}})
+			binary.BigEndian.PutUint32(d[12:], 2)
+			return d
+		},
+		ProblemBlockOutOfFile: func() []byte {
+			d := buildTestBom([][]byte{[]byte("a")}, []Var{{Index: 1, Name: "A"}})
+			binary.BigEndian.PutUint32(pointer(d, 1)[4:], 1<<20)
+			return d
+		},
+		ProblemBlockOverlap: func() []byte {
+			d := buildTestBom([][]byte{[]byte("a"), []byte("b")}, []Var{{Index: 1, Name: "A"}, {Index: 2, Name: "B"}})
+			binary.BigEndian.PutUint32(pointer(d, 2)[4:], 100)
+			return d
+		},
+		ProblemVarNullBlock: func() []byte {
+			return buildTestBom([][]byte{[]byte("a")}, []Var{{Index: 1, Name: "A"}, {Index: 0, Name: "NULL"}})
+		},
+		ProblemVarMissingBlock: func() []byte {
+			return buildTestBom([][]byte{[]byte("a")}, []Var{{Index: 1, Name: "A"}, {Index: 9, Name: "MISSING"}})
+		},
+		ProblemTreePathCount: func() []byte {
+			return buildTestBom([][]byte{
+				testTreeEntry(2, 5),
+				testTreePage(true, 0, 0, []TreeIndex{{ValueIndex: 3, KeyIndex: 4}}),
+				[]byte("v"), []byte("k"),
+			}, []Var{{Index: 1, Name: "TREE"}})
+		},
+		ProblemTreeBroken: func() []byte {
+			return buildTestBom([][]byte{
+				testTreeEntry(2, 1),
+				testTreePage(true, 0, 0, []TreeIndex{{ValueIndex: 7, KeyIndex: 3}}),
+				[]byte("k"),
+			}, []Var{{Index: 1, Name: "TREE"}})
+		},
+		ProblemTreeLinks: func() []byte {
+			// Backward of the second leaf page points to the third one
+			d := buildMultiPageTree(false)
+			binary.BigEndian.PutUint32(d[binary.BigEndian.Uint32(pointer(d, 4))+8:], 5)
+			return d
+		},
+		ProblemPathLoop: func() []byte {
+			return buildTestPathsBom([]testPath{
+				{id: 1, parent: 0, name: ".", info: PathInfo2{Type: PathTypeDir}},
+				{id: 2, parent: 3, name: "a", info: PathInfo2{Type: PathTypeDir}},
+				{id: 3, parent: 2, name: "b", info: PathInfo2{Type: PathTypeDir}},
+			})
+		},
+	}
+	for kind, build := range cases {
+		r := verifyBytes(t, build())
+		if !r.Has(kind) {
+			t.Errorf("%v: %v", kind, r.Problems)
+		}
+	}
+}
+
+func TestReadTreeMissingValue(t *testing.T) {
+	d := buildTestBom([][]byte{
+		testTreeEntry(2, 1),
+		testTreePage(true, 0, 0, []TreeIndex{{ValueIndex: 7, KeyIndex: 3}}),
+		[]byte("k"),
+	}, []Var{{Index: 1, Name: "TREE"}})
+	b := New(bytes.NewReader(d))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ReadTree("TREE", func(k io.Reader, d io.Reader) error {
+		return nil
+	}); !errors.Is(err, ErrBlockNotFound) {
+		t.Fatalf("want ErrBlockNotFound, got: %v", err)
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	d := buildTestBom([][]byte{[]byte("a")}, []Var{{Index: 1, Name: "A"}})
+	for _, n := range []int{10, 520, len(d) - 3} {
+		if err := New(bytes.NewReader(d[:n])).Parse(); err == nil {
+			t.Errorf("truncated at %d: want error", n)
+		}
+	}
+}
+
+// parent loops are reported once by Verify, before Paths fails with ErrPathLoop
+func TestVerifyPathLoop(t *testing.T) {
+	r := verifyBytes(t, buildTestPathsBom([]testPath{
+		{id: 1, parent: 0, name: ".", info: PathInfo2{Type: PathTypeDir}},
+		// 4 -> 3 -> 2 -> 3 is one loop
+		{id: 4, parent: 3, name: "c", info: PathInfo2{Type: PathTypeFile}},
+		{id: 2, parent: 3, name: "a", info: PathInfo2{Type: PathTypeDir}},
+		{id: 3, parent: 2, name: "b", info: PathInfo2{Type: PathTypeDir}},
+		{id: 5, parent: 5, name: "self", info: PathInfo2{Type: PathTypeDir}},
+		// parent not in tree is not a loop
+		{id: 6, parent: 9, name: "orphan", info: PathInfo2{Type: PathTypeFile}},
+	}))
+	loops := []string{}
+	for _, p := range r.Problems {
+		if p.Kind != ProblemPathLoop {
+			t.Fatalf("problem: %v", p)
+		}
+		loops = append(loops, p.Message)
+	}
+	if len(loops) != 2 || loops[0] != "ids 2 -> 3 -> 2" || loops[1] != "ids 5 -> 5" {
+		t.Fatalf("loops: %q", loops)
+	}
+}
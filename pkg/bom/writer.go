@@ -0,0 +1,346 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/iineva/bom/pkg/helper"
+)
+
+const (
+	// size of Header
+	headerSize = 512
+	// size of Tree page header: isLeaf, count, forward, backward
+	treePageHeaderSize = 12
+	// size of TreeIndex
+	treeIndexSize = 8
+	// size of TreeEntry
+	treeEntrySize = 21
+	// size of PathInfo2 without link name and archs
+	pathInfo2Size = 31
+
+	DefaultBlockSize = 4096
+)
+
+var (
+	ErrNameTooLong    = errors.New("var name too long")
+	ErrNameExists     = errors.New("var name already exists")
+	ErrBlockSizeSmall = errors.New("tree block size too small")
+	ErrFileTooLarge   = errors.New("file too large")
+	ErrInlineKeySize  = errors.New("inline key is not 4 bytes")
+)
+
+// TreeItem: key value pair of tree
+type TreeItem struct {
+	Key   []byte
+	Value []byte
+}
+
+// Writer: build BOMStore file from blocks, vars and trees
+//
+//	w := bom.NewWriter()
+//	w.WriteBlock("CARHEADER", header)
+//	w.WriteTree("FACETKEYS", items)
+//	w.WriteTo(f)
+type Writer struct {
+	// page size of trees written after set, default DefaultBlockSize
+	// a page holds no more than 65535 entries, larger pages are padded
+	BlockSize uint32
+
+	// index 0 is the null entry
+	blocks [][]byte
+	vars   []Var
+}
+
+func NewWriter() *Writer {
+	return &Writer{
+		BlockSize: DefaultBlockSize,
+		blocks:    [][]byte{nil},
+	}
+}
+
+// AddBlock: add unnamed block, returns block table index
+func (w *Writer) AddBlock(data []byte) uint32 {
+	w.blocks = append(w.blocks, data)
+	return uint32(len(w.blocks) - 1)
+}
+
+// AddVar: name block of index
+func (w *Writer) AddVar(name string, index uint32) error {
+	if len(name) > 0xff {
+		return ErrNameTooLong
+	}
+	if index == 0 || index >= uint32(len(w.blocks)) {
+		return ErrBlockNotFound
+	}
+	for _, v := range w.vars {
+		if v.Name == name {
+			return ErrNameExists
+		}
+	}
+	w.vars = append(w.vars, Var{Index: index, Length: uint8(len(name)), Name: name})
+	return nil
+}
+
+// WriteBlock: add named block, returns block table index
+func (w *Writer) WriteBlock(name string, data []byte) (uint32, error) {
+	index := w.AddBlock(data)
+	if err := w.AddVar(name, index); err != nil {
+		return 0, err
+	}
+	return index, nil
+}
+
+// WriteTree: add named B+tree, returns block table index of tree entry
+// items must be in key order of the tree, each key and value is stored as a block
+func (w *Writer) WriteTree(name string, items []TreeItem) (uint32, error) {
+	index, err := w.AddTree(items)
+	if err != nil {
+		return 0, err
+	}
+	if err := w.AddVar(name, index); err != nil {
+		return 0, err
+	}
+	return index, nil
+}
+
+// AddTree: add unnamed B+tree, returns block table index of tree entry
+func (w *Writer) AddTree(items []TreeItem) (uint32, error) {
+	list := make([]TreeIndex, len(items))
+	for i, v := range items {
+		list[i].KeyIndex = w.AddBlock(v.Key)
+		list[i].ValueIndex = w.AddBlock(v.Value)
+	}
+	return w.addTree(list, 0)
+}
+
+// WriteInlineTree: add named B+tree with inline keys, like BITMAPKEYS, returns block table index of tree entry
+// every key is a 4 bytes big endian uint32, items must be in key order
+func (w *Writer) WriteInlineTree(name string, items []TreeItem) (uint32, error) {
+	index, err := w.AddInlineTree(items)
+	if err != nil {
+		return 0, err
+	}
+	if err := w.AddVar(name, index); err != nil {
+		return 0, err
+	}
+	return index, nil
+}
+
+// AddInlineTree: add unnamed B+tree with inline keys, returns block table index of tree entry
+func (w *Writer) AddInlineTree(items []TreeItem) (uint32, error) {
+	list := make([]TreeIndex, len(items))
+	for i, v := range items {
+		if len(v.Key) != 4 {
+			return 0, ErrInlineKeySize
+		}
+		list[i].KeyIndex = binary.BigEndian.Uint32(v.Key)
+		list[i].ValueIndex = w.AddBlock(v.Value)
+	}
+	return w.addTree(list, 1)
+}
+
+// addTree: build pages of tree from leaf entries
+func (w *Writer) addTree(list []TreeIndex, unknown3 uint8) (uint32, error) {
+	blockSize := w.BlockSize
+	if blockSize == 0 {
+		blockSize = DefaultBlockSize
+	}
+	if blockSize < treePageHeaderSize+2*treeIndexSize {
+		return 0, ErrBlockSizeSmall
+	}
+	perPage := int(blockSize-treePageHeaderSize) / treeIndexSize
+	if perPage > math.MaxUint16 {
+		// count of page is uint16, rest of large pages is padding
+		perPage = math.MaxUint16
+	}
+
+	// reserve the tree entry first
+	entryIndex := w.AddBlock(nil)
+
+	// leaf pages, linked with Forward and Backward
+	pages := w.addPages(list, int(blockSize), perPage, true, func(page []TreeIndex) uint32 {
+		if len(page) == 0 {
+			return 0
+		}
+		return page[len(page)-1].KeyIndex
+	})
+
+	// branch pages until there is only one root page
+	for len(pages) > 1 {
+		pages = w.addPages(pages, int(blockSize), perPage, false, func(page []TreeIndex) uint32 {
+			return page[len(page)-1].KeyIndex
+		})
+	}
+
+	entry := &TreeEntry{
+		Tag:       helper.NewString4("tree"),
+		Version:   1,
+		Index:     pages[0].ValueIndex,
+		BlockSize: blockSize,
+		PathCount: uint32(len(list)),
+		Unknown3:  unknown3,
+	}
+	buf := &bytes.Buffer{}
+	if err := binary.Write(buf, binary.BigEndian, entry); err != nil {
+		return 0, err
+	}
+	w.blocks[entryIndex] = buf.Bytes()
+
+	return entryIndex, nil
+}
+
+// addPages: split entries into pages of one level,
+// returns one branch entry for each page, key of branch entry is the last key of the page
+func (w *Writer) addPages(list []TreeIndex, blockSize, perPage int, isLeaf bool, lastKey func(page []TreeIndex) uint32) []TreeIndex {
+	n := (len(list) + perPage - 1) / perPage
+	if n == 0 {
+		n = 1
+	}
+
+	// reserve pages first to know indexes of siblings
+	first := uint32(len(w.blocks))
+	for i := 0; i < n; i++ {
+		w.AddBlock(nil)
+	}
+
+	parent := make([]TreeIndex, n)
+	for i := 0; i < n; i++ {
+		start := i * perPage
+		end := start + perPage
+		if end > len(list) {
+			end = len(list)
+		}
+		page := &Tree{List: list[start:end]}
+		if isLeaf {
+			page.IsLeaf = 1
+			if i > 0 {
+				page.Backward = first + uint32(i) - 1
+			}
+			if i < n-1 {
+				page.Forward = first + uint32(i) + 1
+			}
+		}
+		index := first + uint32(i)
+		w.blocks[index] = encodePage(page, blockSize)
+		parent[i] = TreeIndex{ValueIndex: index, KeyIndex: lastKey(page.List)}
+	}
+	return parent
+}
+
+// encodePage: encode tree page, padding to size
+func encodePage(page *Tree, size int) []byte {
+	l := treePageHeaderSize + len(page.List)*treeIndexSize
+	if size < l {
+		size = l
+	}
+	d := make([]byte, size)
+	binary.BigEndian.PutUint16(d[0:], page.IsLeaf)
+	binary.BigEndian.PutUint16(d[2:], uint16(len(page.List)))
+	binary.BigEndian.PutUint32(d[4:], page.Forward)
+	binary.BigEndian.PutUint32(d[8:], page.Backward)
+	for i, v := range page.List {
+		o := treePageHeaderSize + i*treeIndexSize
+		binary.BigEndian.PutUint32(d[o:], v.ValueIndex)
+		binary.BigEndian.PutUint32(d[o+4:], v.KeyIndex)
+	}
+	return d
+}
+
+// WriteTo: write BOMStore file
+// layout: header, blocks, block table with an empty free list, vars
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	buf := &bytes.Buffer{}
+	buf.Write(make([]byte, headerSize))
+
+	pointers := make([]Pointer, len(w.blocks))
+	numberOfBlocks := uint32(0)
+	for i, v := range w.blocks {
+		if i == 0 {
+			// First entry must always be a null entry
+			continue
+		}
+		pointers[i] = Pointer{Address: uint32(buf.Len()), Length: uint32(len(v))}
+		buf.Write(v)
+		numberOfBlocks++
+	}
+
+	// block table
+	indexOffset := buf.Len()
+	buf.Write(encodeIndex(pointers, nil))
+	indexLength := buf.Len() - indexOffset
+
+	// vars
+	varsOffset := buf.Len()
+	buf.Write(encodeVars(w.vars))
+	varsLength := buf.Len() - varsOffset
+
+	header := &Header{
+		Magic:          helper.NewString8(HeaderMagic),
+		Version:        1,
+		NumberOfBlocks: numberOfBlocks,
+		IndexOffset:    uint32(indexOffset),
+		IndexLength:    uint32(indexLength),
+		VarsOffset:     uint32(varsOffset),
+		VarsLength:     uint32(varsLength),
+	}
+	d := buf.Bytes()
+	if int64(len(d)) > math.MaxUint32 {
+		return 0, ErrFileTooLarge
+	}
+	copy(d, encodeHeader(header))
+
+	n, err := out.Write(d)
+	return int64(n), err
+}
+
+// encodeHeader: encode header fields, caller pads to headerSize
+func encodeHeader(h *Header) []byte {
+	d := make([]byte, len(h.Magic)+6*4)
+	o := copy(d, h.Magic[:])
+	for _, v := range []uint32{h.Version, h.NumberOfBlocks, h.IndexOffset, h.IndexLength, h.VarsOffset, h.VarsLength} {
+		binary.BigEndian.PutUint32(d[o:], v)
+		o += 4
+	}
+	return d
+}
+
+// encodeIndex: encode block table followed by free list
+func encodeIndex(pointers, free []Pointer) []byte {
+	d := make([]byte, 4+len(pointers)*8+4+len(free)*8)
+	binary.BigEndian.PutUint32(d, uint32(len(pointers)))
+	o := 4
+	for _, p := range pointers {
+		binary.BigEndian.PutUint32(d[o:], p.Address)
+		binary.BigEndian.PutUint32(d[o+4:], p.Length)
+		o += 8
+	}
+	binary.BigEndian.PutUint32(d[o:], uint32(len(free)))
+	o += 4
+	for _, p := range free {
+		binary.BigEndian.PutUint32(d[o:], p.Address)
+		binary.BigEndian.PutUint32(d[o+4:], p.Length)
+		o += 8
+	}
+	return d
+}
+
+// encodeVars: encode vars with count
+func encodeVars(vars []Var) []byte {
+	n := 4
+	for _, v := range vars {
+		n += 5 + len(v.Name)
+	}
+	d := make([]byte, n)
+	binary.BigEndian.PutUint32(d, uint32(len(vars)))
+	o := 4
+	for _, v := range vars {
+		binary.BigEndian.PutUint32(d[o:], v.Index)
+		d[o+4] = uint8(len(v.Name))
+		o += 5 + copy(d[o+5:], v.Name)
+	}
+	return d
+}
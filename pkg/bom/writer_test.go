@@ -0,0 +1,213 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	w := NewWriter()
+	if _, err := w.WriteBlock("BLOCK", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteBlock("BLOCK", []byte("again")); err != ErrNameExists {
+		t.Fatalf("want ErrNameExists, got: %v", err)
+	}
+
+	// small pages to get a tree with three levels
+	w.BlockSize = 128
+	items := []TreeItem{}
+	for i := 0; i < 1000; i++ {
+		items = append(items, TreeItem{
+			Key:   []byte(fmt.Sprintf("key%04d", i)),
+			Value: []byte(fmt.Sprintf("value%d", i)),
+		})
+	}
+	if _, err := w.WriteTree("TREE", items); err != nil {
+		t.Fatal(err)
+	}
+	w.BlockSize = DefaultBlockSize
+	if _, err := w.WriteTree("EMPTY", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(bytes.NewReader(buf.Bytes()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{"BLOCK", "TREE", "EMPTY"}, b.BlockNames()) {
+		t.Fatalf("names: %v", b.BlockNames())
+	}
+
+	r, err := b.ReadBlock("BLOCK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := ioutil.ReadAll(r); string(d) != "hello" {
+		t.Fatalf("BLOCK: %s", d)
+	}
+
+	i := 0
+	if err := b.ReadTree("TREE", func(k io.Reader, d io.Reader) error {
+		kb, _ := ioutil.ReadAll(k)
+		db, _ := ioutil.ReadAll(d)
+		if !bytes.Equal(items[i].Key, kb) || !bytes.Equal(items[i].Value, db) {
+			return fmt.Errorf("entry %d: %s %s", i, kb, db)
+		}
+		i++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if i != len(items) {
+		t.Fatalf("want %d entries, got: %d", len(items), i)
+	}
+
+	for _, v := range items {
+		r, err := b.Lookup("TREE", v.Key, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Key, err)
+		}
+		if d, _ := ioutil.ReadAll(r); !bytes.Equal(v.Value, d) {
+			t.Fatalf("%s: %s", v.Key, d)
+		}
+	}
+
+	it, err := b.Iterator("TREE", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i = len(items)
+	for it.Prev() {
+		i--
+		if !bytes.Equal(items[i].Key, it.Key()) {
+			t.Fatalf("Prev %d: %s", i, it.Key())
+		}
+	}
+	if i != 0 || it.Err() != nil {
+		t.Fatalf("Prev stopped at %d: %v", i, it.Err())
+	}
+
+	if err := b.ReadTree("EMPTY", func(k io.Reader, d io.Reader) error {
+		return fmt.Errorf("EMPTY is not empty")
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// copy every block and tree of Assets.car with Writer
+func TestWriterRoundTrip(t *testing.T) {
+	f, err := openTestData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	src := New(f)
+	if err := src.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	trees := []string{"RENDITIONS", "FACETKEYS", "APPEARANCEKEYS"}
+	readTree := func(b BomParser, name string) []TreeItem {
+		items := []TreeItem{}
+		if err := b.ReadTree(name, func(k io.Reader, d io.Reader) error {
+			kb, err := ioutil.ReadAll(k)
+			if err != nil {
+				return err
+			}
+			db, err := ioutil.ReadAll(d)
+			if err != nil {
+				return err
+			}
+			items = append(items, TreeItem{Key: kb, Value: db})
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return items
+	}
+
+	w := NewWriter()
+	for _, name := range []string{"CARHEADER", "KEYFORMAT", "EXTENDED_METADATA"} {
+		r, err := src.ReadBlock(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.WriteBlock(name, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range trees {
+		if _, err := w.WriteTree(name, readTree(src, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := New(bytes.NewReader(buf.Bytes()))
+	if err := dst.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range trees {
+		if !reflect.DeepEqual(readTree(src, name), readTree(dst, name)) {
+			t.Fatalf("tree %v not match", name)
+		}
+	}
+}
+
+// pages of large block size hold no more than 65535 entries, count of page is uint16
+func TestWriterLargeBlockSize(t *testing.T) {
+	const n = math.MaxUint16 + 10
+	items := make([]TreeItem, n)
+	for i := range items {
+		items[i].Key = make([]byte, 4)
+		binary.BigEndian.PutUint32(items[i].Key, uint32(i))
+	}
+	w := NewWriter()
+	w.BlockSize = treePageHeaderSize + (n+1)*treeIndexSize
+	if _, err := w.WriteInlineTree("TREE", items); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(bytes.NewReader(buf.Bytes()))
+	if err := b.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	if err := b.ReadTree("TREE", func(k io.Reader, d io.Reader) error {
+		count++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != n {
+		t.Fatalf("entries: %d", count)
+	}
+	key := items[n-1].Key
+	if _, err := b.Lookup("TREE", key, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,53 @@
+package bom
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// OpenZipEntry: content of zip entry f as io.ReaderAt, ra is the whole zip file f is read from
+// stored entries are read in place through ra, without checking CRC,
+// other entries are decompressed into memory, no more than max bytes, max 0 means DefaultOptions.MaxDecompressedBytes
+func OpenZipEntry(ra io.ReaderAt, f *zip.File, max int64) (io.ReaderAt, int64, error) {
+	if max == 0 {
+		max = DefaultOptions.MaxDecompressedBytes
+	}
+	if f.Method == zip.Store && f.CompressedSize64 == f.UncompressedSize64 {
+		offset, err := f.DataOffset()
+		if err != nil {
+			return nil, 0, err
+		}
+		size := int64(f.UncompressedSize64)
+		return io.NewSectionReader(ra, offset, size), size, nil
+	}
+
+	if f.UncompressedSize64 > uint64(max) {
+		return nil, 0, &LimitError{Limit: "MaxDecompressedBytes", Value: int64(f.UncompressedSize64), Max: max}
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+	// size in header may be wrong, never read more than max
+	d, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(d)) > max {
+		return nil, 0, &LimitError{Limit: "MaxDecompressedBytes", Value: int64(len(d)), Max: max}
+	}
+	return bytes.NewReader(d), int64(len(d)), nil
+}
+
+// NewWithZipEntry: parser of zip entry f, ra is the whole zip file, see OpenZipEntry
+func NewWithZipEntry(ra io.ReaderAt, f *zip.File, opts Options) (BomStore, error) {
+	opts = opts.withDefaults()
+	r, size, err := OpenZipEntry(ra, f, opts.MaxDecompressedBytes)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithOptions(r, size, opts), nil
+}
@@ -0,0 +1,93 @@
+package helper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Cursor: bounds-checked reader of byte slice, decodes fixed size fields without reflection
+// the first error sticks, reads after it return zero values, check Err once after decoding a struct
+type Cursor struct {
+	d     []byte
+	off   int
+	order binary.ByteOrder
+	err   error
+}
+
+func NewCursor(d []byte, order binary.ByteOrder) *Cursor {
+	return &Cursor{d: d, order: order}
+}
+
+// next: n bytes at offset, nil and io.ErrUnexpectedEOF if not enough bytes
+func (c *Cursor) next(n int) []byte {
+	if c.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(c.d)-c.off {
+		c.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	p := c.d[c.off : c.off+n : c.off+n]
+	c.off += n
+	return p
+}
+
+func (c *Cursor) Uint8() uint8 {
+	p := c.next(1)
+	if p == nil {
+		return 0
+	}
+	return p[0]
+}
+
+func (c *Cursor) Uint16() uint16 {
+	p := c.next(2)
+	if p == nil {
+		return 0
+	}
+	return c.order.Uint16(p)
+}
+
+func (c *Cursor) Uint32() uint32 {
+	p := c.next(4)
+	if p == nil {
+		return 0
+	}
+	return c.order.Uint32(p)
+}
+
+func (c *Cursor) Uint64() uint64 {
+	p := c.next(8)
+	if p == nil {
+		return 0
+	}
+	return c.order.Uint64(p)
+}
+
+// Bytes: next n bytes, shares memory with the slice of cursor
+func (c *Cursor) Bytes(n int) []byte {
+	return c.next(n)
+}
+
+// Fill: fill p, like String4 and other fixed size arrays
+func (c *Cursor) Fill(p []byte) {
+	copy(p, c.next(len(p)))
+}
+
+func (c *Cursor) Skip(n int) {
+	c.next(n)
+}
+
+// Offset: bytes read
+func (c *Cursor) Offset() int {
+	return c.off
+}
+
+// Len: bytes left
+func (c *Cursor) Len() int {
+	return len(c.d) - c.off
+}
+
+func (c *Cursor) Err() error {
+	return c.err
+}
@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	d := []byte{'t', 'r', 'e', 'e', 0x01, 0x02, 0x01, 0x02, 0x03, 0x04, 0xff, 0xaa, 0xbb}
+	c := NewCursor(d, binary.BigEndian)
+	s := String4{}
+	c.Fill(s[:])
+	if s.String() != "tree" || c.Uint16() != 0x0102 || c.Uint32() != 0x01020304 || c.Uint8() != 0xff {
+		t.Fatal("big endian")
+	}
+	if c.Offset() != 11 || c.Len() != 2 || c.Err() != nil {
+		t.Fatalf("offset %d, len %d, %v", c.Offset(), c.Len(), c.Err())
+	}
+	// short read sets error, later reads return zero values
+	if c.Uint32() != 0 || c.Err() != io.ErrUnexpectedEOF {
+		t.Fatalf("short: %v", c.Err())
+	}
+	if c.Uint8() != 0 || c.Bytes(1) != nil || c.Offset() != 11 {
+		t.Fatal("sticky error")
+	}
+
+	c = NewCursor(d[4:], binary.LittleEndian)
+	if c.Uint16() != 0x0201 || c.Uint32() != 0x04030201 {
+		t.Fatal("little endian")
+	}
+	c.Skip(1)
+	if b := c.Bytes(2); len(b) != 2 || b[0] != 0xaa || c.Len() != 0 || c.Err() != nil {
+		t.Fatalf("bytes: %x %v", b, c.Err())
+	}
+	c.Skip(-1)
+	if c.Err() != io.ErrUnexpectedEOF {
+		t.Fatalf("negative skip: %v", c.Err())
+	}
+}
@@ -0,0 +1,251 @@
+// io.ReaderAt of remote file, backed by HTTP Range requests
+package httpreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/iineva/bom/pkg/reader"
+)
+
+var (
+	ErrRangeNotSupported = errors.New("server does not support range requests")
+	ErrRemoteChanged     = errors.New("remote file changed")
+)
+
+// Options: zero fields use values of DefaultOptions
+type Options struct {
+	Client      *http.Client // default http.DefaultClient
+	Header      http.Header  // extra request headers, like Authorization
+	BlockSize   int64        // bytes of each request and cached block
+	ReadAhead   int          // blocks fetched after the requested ones in the same request
+	CacheBlocks int          // max blocks kept in cache, least recently used are dropped
+}
+
+var DefaultOptions = Options{
+	Client:      http.DefaultClient,
+	BlockSize:   64 << 10,
+	ReadAhead:   1,
+	CacheBlocks: 64,
+}
+
+func (o Options) withDefaults() Options {
+	if o.Client == nil {
+		o.Client = DefaultOptions.Client
+	}
+	if o.BlockSize <= 0 {
+		o.BlockSize = DefaultOptions.BlockSize
+	}
+	if o.CacheBlocks <= 0 {
+		o.CacheBlocks = DefaultOptions.CacheBlocks
+	}
+	return o
+}
+
+// Stats: counters of requests and cache
+type Stats struct {
+	Requests int64 // HTTP requests sent
+	Bytes    int64 // body bytes received
+	Hits     int64 // blocks found in cache
+	Misses   int64 // blocks not in cache
+}
+
+// Reader: io.ReaderAt of remote file, safe for parallel use
+type Reader struct {
+	remote *remote
+	cache  *reader.PageCache
+}
+
+// remote: one Range request for each ReadAt, called by cache in parallel
+// size and validator are set by probe before the first ReadAt and never change
+type remote struct {
+	url       string
+	opts      Options
+	size      int64
+	validator string // If-Range value, strong ETag or Last-Modified
+
+	requests int64 // atomic
+	bytes    int64 // atomic
+}
+
+// New: open remote file, a request of the first byte gets file size
+func New(url string, opts Options) (*Reader, error) {
+	opts = opts.withDefaults()
+	rm := &remote{url: url, opts: opts}
+	if err := rm.probe(); err != nil {
+		return nil, err
+	}
+	return &Reader{
+		remote: rm,
+		cache: reader.NewPageCache(rm, reader.CacheOptions{
+			PageSize:  opts.BlockSize,
+			Pages:     opts.CacheBlocks,
+			ReadAhead: opts.ReadAhead,
+		}),
+	}, nil
+}
+
+// Size: size of remote file
+func (r *Reader) Size() int64 {
+	return r.remote.size
+}
+
+// Stats: copy of counters
+func (r *Reader) Stats() Stats {
+	c := r.cache.Stats()
+	return Stats{
+		Requests: atomic.LoadInt64(&r.remote.requests),
+		Bytes:    atomic.LoadInt64(&r.remote.bytes),
+		Hits:     c.Hits,
+		Misses:   c.Misses,
+	}
+}
+
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("httpreader: negative offset")
+	}
+	return r.cache.ReadAt(p, off)
+}
+
+// get: send GET request of bytes [start, end]
+func (r *remote) get(start, end int64, validator string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.opts.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if validator != "" {
+		// server sends the whole file if it changed
+		req.Header.Set("If-Range", validator)
+	}
+	atomic.AddInt64(&r.requests, 1)
+	return r.opts.Client.Do(req)
+}
+
+// probe: size from Content-Range of the first byte, and validator of later requests,
+// an empty file is 416 with Content-Range "bytes */0", or 200 with empty body
+func (r *remote) probe() error {
+	resp, err := r.get(0, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		_, _, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.url, err)
+		}
+		n, _ := io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1))
+		atomic.AddInt64(&r.bytes, n)
+		r.size = size
+	case http.StatusRequestedRangeNotSatisfiable:
+		if resp.Header.Get("Content-Range") != "bytes */0" {
+			return fmt.Errorf("%s: %s", r.url, resp.Status)
+		}
+		r.size = 0
+	case http.StatusOK:
+		if resp.ContentLength != 0 {
+			return fmt.Errorf("%s: %w", r.url, ErrRangeNotSupported)
+		}
+		r.size = 0
+	default:
+		return fmt.Errorf("%s: %s", r.url, resp.Status)
+	}
+	r.validator = ifRange(resp.Header)
+	return nil
+}
+
+// ifRange: If-Range value of response header, weak ETags never match If-Range,
+// so Last-Modified is used instead, empty if neither can be used
+func ifRange(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+func (r *remote) ReadAt(p []byte, off int64) (int, error) {
+	start := off
+	end := start + int64(len(p))
+	if end > r.size {
+		end = r.size
+	}
+	if start >= end {
+		return 0, io.EOF
+	}
+
+	resp, err := r.get(start, end-1, r.validator)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// body is the whole new file, closed without reading
+		return 0, fmt.Errorf("%s: %w", r.url, ErrRemoteChanged)
+	default:
+		return 0, fmt.Errorf("%s: %s", r.url, resp.Status)
+	}
+
+	first, last, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", r.url, err)
+	}
+	if size != r.size {
+		return 0, fmt.Errorf("%s: %w", r.url, ErrRemoteChanged)
+	}
+	if first != start || last != end-1 {
+		return 0, fmt.Errorf("%s: unexpected Content-Range %d-%d, want %d-%d", r.url, first, last, start, end-1)
+	}
+
+	n, err := io.ReadFull(resp.Body, p[:end-start])
+	atomic.AddInt64(&r.bytes, int64(n))
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// parseContentRange: "bytes first-last/size", size "*" is not supported
+func parseContentRange(s string) (first, last, size int64, err error) {
+	bad := fmt.Errorf("bad Content-Range %q", s)
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, 0, bad
+	}
+	s = strings.TrimPrefix(s, "bytes ")
+	i := strings.IndexByte(s, '-')
+	j := strings.IndexByte(s, '/')
+	if i < 0 || j < i {
+		return 0, 0, 0, bad
+	}
+	if first, err = strconv.ParseInt(s[:i], 10, 64); err != nil {
+		return 0, 0, 0, bad
+	}
+	if last, err = strconv.ParseInt(s[i+1:j], 10, 64); err != nil {
+		return 0, 0, 0, bad
+	}
+	if size, err = strconv.ParseInt(s[j+1:], 10, 64); err != nil {
+		return 0, 0, 0, bad
+	}
+	if first > last || last >= size {
+		return 0, 0, 0, bad
+	}
+	return first, last, size, nil
+}
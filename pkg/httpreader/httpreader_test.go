@@ -0,0 +1,262 @@
+package httpreader
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iineva/bom/pkg/asset"
+	"github.com/iineva/bom/pkg/bom"
+)
+
+// newServer: serve d with range support, count requests
+func newServer(d []byte, requests *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(requests, 1)
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, req, "file", time.Time{}, bytes.NewReader(d))
+	}))
+}
+
+func TestReadAt(t *testing.T) {
+	d := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(d)
+	var requests int64
+	s := newServer(d, &requests)
+	defer s.Close()
+
+	r, err := New(s.URL, Options{BlockSize: 1000, ReadAhead: 2, CacheBlocks: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(d)) {
+		t.Fatalf("size: %d", r.Size())
+	}
+
+	cases := []struct{ off, len int64 }{{0, 10}, {990, 20}, {2500, 1000}, {9990, 10}, {0, 10000}, {5000, 3000}}
+	for _, c := range cases {
+		p := make([]byte, c.len)
+		n, err := r.ReadAt(p, c.off)
+		if err != nil || int64(n) != c.len || !bytes.Equal(p, d[c.off:c.off+c.len]) {
+			t.Fatalf("ReadAt(%d, %d): %d %v", c.off, c.len, n, err)
+		}
+	}
+
+	// read past end of file
+	p := make([]byte, 20)
+	if n, err := r.ReadAt(p, 9990); n != 10 || err != io.EOF {
+		t.Fatalf("end: %d %v", n, err)
+	}
+	if _, err := r.ReadAt(p, 10000); err != io.EOF {
+		t.Fatalf("eof: %v", err)
+	}
+
+	st := r.Stats()
+	if st.Requests != requests || st.Hits == 0 || st.Bytes > 3*int64(len(d)) {
+		t.Fatalf("stats: %+v, server requests %d", st, requests)
+	}
+
+	// cached blocks are not fetched again
+	before := r.Stats().Requests
+	if _, err := r.ReadAt(p, 5000); err != nil || r.Stats().Requests != before {
+		t.Fatalf("cache: %v %+v", err, r.Stats())
+	}
+}
+
+func TestOpen(t *testing.T) {
+	d := make([]byte, 10000)
+	var requests int64
+	s := newServer(d, &requests)
+	defer s.Close()
+
+	// size is from Content-Range of the first byte, no block is fetched
+	r, err := New(s.URL, Options{BlockSize: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := r.Stats(); r.Size() != 10000 || st.Requests != 1 || st.Bytes != 1 || st.Misses != 0 {
+		t.Fatalf("size %d, stats %+v", r.Size(), st)
+	}
+
+	s = newServer(nil, &requests)
+	defer s.Close()
+	r, err = New(s.URL, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadAt(make([]byte, 1), 0); r.Size() != 0 || err != io.EOF {
+		t.Fatalf("empty: %d %v", r.Size(), err)
+	}
+}
+
+// TestParallel: a request in flight does not block reads of other blocks
+func TestParallel(t *testing.T) {
+	d := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(d)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Range") == "bytes=5000-5999" {
+			started <- struct{}{}
+			<-release
+		}
+		http.ServeContent(w, req, "file", time.Time{}, bytes.NewReader(d))
+	}))
+	defer s.Close()
+	defer close(release)
+
+	r, err := New(s.URL, Options{BlockSize: 1000, ReadAhead: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow := make(chan error, 1)
+	go func() {
+		_, err := r.ReadAt(make([]byte, 10), 5000)
+		slow <- err
+	}()
+	<-started
+
+	done := make(chan error, 1)
+	p := make([]byte, 10)
+	go func() {
+		_, err := r.ReadAt(p, 100)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil || !bytes.Equal(p, d[100:110]) {
+			t.Fatalf("read: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read blocked by request in flight")
+	}
+	release <- struct{}{}
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	d := []byte("no range")
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write(d)
+	}))
+	defer s.Close()
+	if _, err := New(s.URL, Options{}); !errors.Is(err, ErrRangeNotSupported) {
+		t.Fatalf("range: %v", err)
+	}
+
+	s = httptest.NewServer(http.NotFoundHandler())
+	defer s.Close()
+	if _, err := New(s.URL, Options{}); err == nil {
+		t.Fatal("not found: no error")
+	}
+
+	// file is replaced after open
+	etag := `"v1"`
+	d = make([]byte, 5000)
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, req, "file", time.Time{}, bytes.NewReader(d))
+	}))
+	defer s.Close()
+	r, err := New(s.URL, Options{BlockSize: 1000, ReadAhead: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag = `"v2"`
+	if _, err := r.ReadAt(make([]byte, 10), 4000); !errors.Is(err, ErrRemoteChanged) {
+		t.Fatalf("changed: %v", err)
+	}
+
+	// weak ETag, If-Range uses Last-Modified
+	modTime := time.Unix(1600000000, 0)
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", `W/"v1"`)
+		if v := req.Header.Get("If-Range"); v == `W/"v1"` {
+			t.Errorf("If-Range: %q", v)
+		}
+		http.ServeContent(w, req, "file", modTime, bytes.NewReader(d))
+	}))
+	defer s.Close()
+	r, err = New(s.URL, Options{BlockSize: 1000, ReadAhead: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadAt(make([]byte, 10), 4000); err != nil {
+		t.Fatalf("weak etag: %v", err)
+	}
+	modTime = modTime.Add(time.Hour)
+	if _, err := r.ReadAt(make([]byte, 10), 3000); !errors.Is(err, ErrRemoteChanged) {
+		t.Fatalf("weak etag changed: %v", err)
+	}
+
+	for _, v := range []string{"", "bytes 1-0/5", "bytes 0-9/5", "bytes 0-4/*", "items 0-4/5"} {
+		if _, _, _, err := parseContentRange(v); err == nil {
+			t.Fatalf("%q: no error", v)
+		}
+	}
+}
+
+// TestRemoteIPA: read app icon of large remote IPA, fetching only a small part of it
+func TestRemoteIPA(t *testing.T) {
+	car, err := ioutil.ReadFile("../bom/test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(big)
+
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, f := range []struct {
+		name string
+		d    []byte
+	}{
+		{"Payload/Test.app/Test", big},
+		{"Payload/Test.app/Assets.car", car},
+		{"Payload/Test.app/Info.plist", []byte("plist")},
+	} {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(f.d)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int64
+	s := newServer(buf.Bytes(), &requests)
+	defer s.Close()
+
+	r, err := New(s.URL, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := asset.NewWithZip(r, r.Size(), bom.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := a.Image("AppIcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 180 {
+		t.Fatalf("AppIcon: %v", img.Bounds())
+	}
+	st := r.Stats()
+	if st.Bytes > 512<<10 {
+		t.Fatalf("fetched %d of %d bytes, %+v", st.Bytes, buf.Len(), st)
+	}
+	t.Logf("fetched %d of %d bytes, %+v", st.Bytes, buf.Len(), st)
+}
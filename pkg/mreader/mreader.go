@@ -46,5 +46,8 @@ func (r *mreader) Close() error {
 			err = append(err, e.Error())
 		}
 	}
+	if len(err) == 0 {
+		return nil
+	}
 	return errors.New(strings.Join(err, ","))
 }
@@ -0,0 +1,220 @@
+package reader
+
+import (
+	"container/list"
+	"io"
+	"sync"
+)
+
+// CacheOptions: zero fields use values of DefaultCacheOptions
+type CacheOptions struct {
+	PageSize  int64 // bytes of each page, reads of r are aligned to pages
+	Pages     int   // max pages kept, least recently used are dropped
+	ReadAhead int   // pages read after the missing one in the same read of r
+}
+
+var DefaultCacheOptions = CacheOptions{
+	PageSize: 32 << 10,
+	Pages:    64,
+}
+
+func (o CacheOptions) withDefaults() CacheOptions {
+	if o.PageSize <= 0 {
+		o.PageSize = DefaultCacheOptions.PageSize
+	}
+	if o.Pages <= 0 {
+		o.Pages = DefaultCacheOptions.Pages
+	}
+	if o.ReadAhead < 0 {
+		o.ReadAhead = 0
+	}
+	if o.Pages < o.ReadAhead+1 {
+		o.Pages = o.ReadAhead + 1
+	}
+	return o
+}
+
+// CacheStats: counters of PageCache
+type CacheStats struct {
+	Hits   int64 // pages found in cache
+	Misses int64 // pages not in cache
+	Reads  int64 // reads of r, one for each miss, read-ahead pages come with it
+	Bytes  int64 // bytes read from r
+}
+
+type page struct {
+	index int64
+	data  []byte
+}
+
+// fetch: read of r in flight, for the missing page and its read-ahead pages
+// readers of these pages wait for done instead of reading r again
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// PageCache: LRU page cache of io.ReaderAt, safe for parallel use
+// every blockReader of one PageCache shares its pages, small reads of slow storage become reads of whole pages
+// reads of r are done without holding the lock, hits are not blocked by a slow miss
+type PageCache struct {
+	r    io.ReaderAt
+	opts CacheOptions
+
+	mu       sync.Mutex
+	pages    map[int64]*list.Element
+	inflight map[int64]*fetch
+	lru      *list.List // front is most recently used
+	eof      int64      // size of r once known, -1 before
+	stats    CacheStats
+}
+
+// NewPageCache: cache pages of r in memory, no more than opts.PageSize * opts.Pages bytes
+func NewPageCache(r io.ReaderAt, opts CacheOptions) *PageCache {
+	return &PageCache{
+		r:        r,
+		opts:     opts.withDefaults(),
+		pages:    map[int64]*list.Element{},
+		inflight: map[int64]*fetch{},
+		lru:      list.New(),
+		eof:      -1,
+	}
+}
+
+// Options: options with defaults applied
+func (c *PageCache) Options() CacheOptions {
+	return c.opts
+}
+
+// Stats: copy of counters
+func (c *PageCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *PageCache) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		index := off / c.opts.PageSize
+		d, err := c.page(index)
+		if err != nil {
+			return n, err
+		}
+		start := off - index*c.opts.PageSize
+		if start >= int64(len(d)) {
+			return n, io.EOF
+		}
+		m := copy(p[n:], d[start:])
+		n += m
+		off += int64(m)
+	}
+	return n, nil
+}
+
+// page: cached page, or read it with read-ahead pages which are not cached
+// the last page of r is short, pages after end of r are empty
+// concurrent misses of one page share one read of r
+func (c *PageCache) page(index int64) ([]byte, error) {
+	start := index * c.opts.PageSize
+	for {
+		c.mu.Lock()
+		if e, ok := c.pages[index]; ok {
+			c.stats.Hits++
+			c.lru.MoveToFront(e)
+			c.mu.Unlock()
+			return e.Value.(*page).data, nil
+		}
+		if c.eof >= 0 && start >= c.eof {
+			c.mu.Unlock()
+			return nil, nil
+		}
+		if f, ok := c.inflight[index]; ok {
+			c.mu.Unlock()
+			<-f.done
+			if f.err != nil {
+				return nil, f.err
+			}
+			// the page is cached now, unless it was dropped already
+			continue
+		}
+		c.stats.Misses++
+
+		count := int64(1)
+		for count <= int64(c.opts.ReadAhead) {
+			next := start + count*c.opts.PageSize
+			if _, ok := c.pages[index+count]; ok || (c.eof >= 0 && next >= c.eof) {
+				break
+			}
+			if _, ok := c.inflight[index+count]; ok {
+				break
+			}
+			count++
+		}
+		f := &fetch{done: make(chan struct{})}
+		for i := int64(0); i < count; i++ {
+			c.inflight[index+i] = f
+		}
+		c.mu.Unlock()
+
+		return c.fetch(index, count, f)
+	}
+}
+
+// fetch: read count pages from index, cache them and wake up readers waiting for f
+func (c *PageCache) fetch(index, count int64, f *fetch) ([]byte, error) {
+	start := index * c.opts.PageSize
+	d := make([]byte, count*c.opts.PageSize)
+	n, err := c.r.ReadAt(d, start)
+	if err == io.EOF {
+		err = nil
+	}
+
+	c.mu.Lock()
+	c.stats.Reads++
+	c.stats.Bytes += int64(n)
+	for i := int64(0); i < count; i++ {
+		delete(c.inflight, index+i)
+	}
+	if err == nil {
+		if n < len(d) {
+			c.eof = start + int64(n)
+		}
+		d = d[:n]
+		for i := int64(0); i < count && i*c.opts.PageSize < int64(n); i++ {
+			c.put(index+i, pageOf(d, i, c.opts.PageSize))
+		}
+	}
+	f.err = err
+	close(f.done)
+	c.mu.Unlock()
+
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	return pageOf(d, 0, c.opts.PageSize), nil
+}
+
+// pageOf: page i of d, the last page may be short
+func pageOf(d []byte, i, size int64) []byte {
+	s := i * size
+	e := s + size
+	if e > int64(len(d)) {
+		e = int64(len(d))
+	}
+	return d[s:e:e]
+}
+
+func (c *PageCache) put(index int64, d []byte) {
+	if e, ok := c.pages[index]; ok {
+		e.Value.(*page).data = d
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.pages[index] = c.lru.PushFront(&page{index: index, data: d})
+	for c.lru.Len() > c.opts.Pages {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.pages, e.Value.(*page).index)
+	}
+}
@@ -0,0 +1,177 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countReader: count ReadAt calls of r
+type countReader struct {
+	r     io.ReaderAt
+	reads int
+}
+
+func (c *countReader) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	return c.r.ReadAt(p, off)
+}
+
+func TestPageCache(t *testing.T) {
+	d := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(d)
+	cr := &countReader{r: bytes.NewReader(d)}
+	c := NewPageCache(cr, CacheOptions{PageSize: 1000, Pages: 4, ReadAhead: 1})
+
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 1000; i++ {
+		off := rnd.Int63n(int64(len(d)) + 100)
+		p := make([]byte, rnd.Intn(3000))
+		n, err := c.ReadAt(p, off)
+		want := int64(len(d)) - off
+		if want < 0 {
+			want = 0
+		}
+		if want > int64(len(p)) {
+			want = int64(len(p))
+		}
+		if int64(n) != want || (n > 0 && !bytes.Equal(p[:n], d[off:off+want])) {
+			t.Fatalf("ReadAt(%d, %d): %d %v", off, len(p), n, err)
+		}
+		if n < len(p) && err != io.EOF {
+			t.Fatalf("ReadAt(%d, %d): short read %d without EOF: %v", off, len(p), n, err)
+		}
+		if n == len(p) && err != nil {
+			t.Fatalf("ReadAt(%d, %d): %v", off, len(p), err)
+		}
+	}
+
+	st := c.Stats()
+	if st.Reads != int64(cr.reads) || st.Hits == 0 || st.Misses == 0 || len(c.pages) > 4 {
+		t.Fatalf("stats: %+v, reads %d, pages %d", st, cr.reads, len(c.pages))
+	}
+
+	// small reads of one page are one read of r
+	cr = &countReader{r: bytes.NewReader(d)}
+	c = NewPageCache(cr, CacheOptions{PageSize: 1000})
+	for off := int64(2000); off < 3000; off += 4 {
+		p := make([]byte, 4)
+		if _, err := c.ReadAt(p, off); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cr.reads != 1 || c.Stats().Hits != 249 {
+		t.Fatalf("reads %d, %+v", cr.reads, c.Stats())
+	}
+
+	// error of r is returned and not cached
+	c = NewPageCache(&errReader{}, CacheOptions{})
+	if _, err := c.ReadAt(make([]byte, 10), 0); !errors.Is(err, errRead) {
+		t.Fatalf("err: %v", err)
+	}
+	if len(c.pages) != 0 {
+		t.Fatalf("pages: %d", len(c.pages))
+	}
+}
+
+var errRead = errors.New("read error")
+
+type errReader struct{}
+
+func (errReader) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errRead
+}
+
+func TestBlockReaderCache(t *testing.T) {
+	d, err := ioutil.ReadFile("../bom/test_data/Assets.car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &countReader{r: bytes.NewReader(d)}
+	c := NewPageCache(cr, CacheOptions{PageSize: 4096})
+	// block readers of the same cache share pages
+	for i := 0; i < 3; i++ {
+		b, err := ioutil.ReadAll(New(c, 100, 5000))
+		if err != nil || !bytes.Equal(b, d[100:5100]) {
+			t.Fatalf("block: %v", err)
+		}
+	}
+	if cr.reads != 2 {
+		t.Fatalf("reads: %d, %+v", cr.reads, c.Stats())
+	}
+}
+
+// blockingReader: reads at or after block wait for release, started is signaled when one begins
+type blockingReader struct {
+	r       io.ReaderAt
+	block   int64
+	started chan struct{}
+	release chan struct{}
+	reads   int32
+}
+
+func (b *blockingReader) ReadAt(p []byte, off int64) (int, error) {
+	atomic.AddInt32(&b.reads, 1)
+	if off >= b.block {
+		b.started <- struct{}{}
+		<-b.release
+	}
+	return b.r.ReadAt(p, off)
+}
+
+func TestPageCacheParallel(t *testing.T) {
+	d := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(d)
+	br := &blockingReader{r: bytes.NewReader(d), block: 5000, started: make(chan struct{}, 10), release: make(chan struct{})}
+	c := NewPageCache(br, CacheOptions{PageSize: 1000, Pages: 10})
+
+	p := make([]byte, 100)
+	if _, err := c.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// two misses of one page share one read of r
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 100)
+			if _, err := c.ReadAt(p, 5500); err != nil || !bytes.Equal(p, d[5500:5600]) {
+				t.Errorf("miss: %v", err)
+			}
+		}()
+	}
+	<-br.started
+
+	// a hit is not blocked by the miss in flight
+	done := make(chan error)
+	go func() {
+		_, err := c.ReadAt(p, 200)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil || !bytes.Equal(p, d[200:300]) {
+			t.Fatalf("hit: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hit blocked by miss in flight")
+	}
+
+	close(br.release)
+	wg.Wait()
+	if reads := atomic.LoadInt32(&br.reads); reads != 2 {
+		t.Fatalf("reads: %d", reads)
+	}
+	st := c.Stats()
+	if st.Reads != 2 || st.Misses != 2 || st.Hits < 2 {
+		t.Fatalf("stats: %+v", st)
+	}
+}
@@ -2,19 +2,21 @@ package reader
 
 import (
 	"io"
+	"sync"
 )
 
 // zero copy reader
+// every blockReader keeps its own offset, many of them can read one io.ReaderAt in parallel
 type blockReader struct {
-	r    io.ReadSeeker
+	r    io.ReaderAt
 	addr int64 // base offset
 	off  int64 // current offset
 	len  int64 // limit read block range
 }
 
-// offset, current io.ReadSeeker offset
+// offset, block offset of io.ReaderAt
 // len, total len to limit read block range
-func New(r io.ReadSeeker, offset, len int64) *blockReader {
+func New(r io.ReaderAt, offset, len int64) *blockReader {
 	return &blockReader{
 		r:    r,
 		addr: offset,
@@ -27,18 +29,49 @@ func (b *blockReader) Read(p []byte) (n int, err error) {
 	if b.off-b.len >= 0 {
 		return 0, io.EOF
 	}
-	addr := b.addr + b.off
-	if _, err := b.r.Seek(int64(addr), 0); err != nil {
-		return 0, err
-	}
 
 	maxLen := b.len - b.off
 	if maxLen > int64(len(p)) {
 		maxLen = int64(len(p))
 	}
 
-	n, err = b.r.Read(p[:maxLen])
+	n, err = b.r.ReadAt(p[:maxLen], b.addr+b.off)
 	b.off += int64(n)
+	if err == io.EOF {
+		if n > 0 {
+			return n, nil
+		}
+		// block ends after end of file
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// seekReaderAt: io.ReaderAt of io.ReadSeeker, Seek and Read are locked together
+type seekReaderAt struct {
+	mu sync.Mutex
+	r  io.ReadSeeker
+}
 
-	return n, nil
+// NewReaderAt: convert io.ReadSeeker to io.ReaderAt which is safe for parallel use
+// returns r itself if it is already an io.ReaderAt, like *os.File
+func NewReaderAt(r io.ReadSeeker) io.ReaderAt {
+	if ra, ok := r.(io.ReaderAt); ok {
+		return ra
+	}
+	return &seekReaderAt{r: r}
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(s.r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
 }